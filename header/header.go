@@ -201,6 +201,57 @@ loop:
 	return
 }
 
+// AcceptParamsSpec describes an Accept* header value including its media type
+// parameters, e.g. "application/json; pretty=true; q=0.5".
+type AcceptParamsSpec struct {
+	AcceptSpec
+	Params map[string]string
+}
+
+// ParseAcceptParams parses Accept* headers like ParseAccept, but also parses
+// the parameters of each value. Parameter names are lower cased, the quality
+// parameter "q" is not included in the parameters.
+func ParseAcceptParams(header http.Header, key string) (specs []AcceptParamsSpec) {
+loop:
+	for _, s := range header[key] {
+		for {
+			var spec AcceptParamsSpec
+			spec.Value, s = expectTokenSlash(s)
+			if spec.Value == "" {
+				continue loop
+			}
+			spec.Q = 1.0
+			spec.Params = make(map[string]string)
+			s = skipSpace(s)
+			for strings.HasPrefix(s, ";") {
+				var pkey string
+				pkey, s = expectToken(skipSpace(s[1:]))
+				if pkey == "" || !strings.HasPrefix(s, "=") {
+					continue loop
+				}
+				pkey = strings.ToLower(pkey)
+				if pkey == "q" {
+					spec.Q, s = expectQuality(s[1:])
+					if spec.Q < 0.0 {
+						continue loop
+					}
+				} else {
+					var pvalue string
+					pvalue, s = expectTokenOrQuoted(s[1:])
+					spec.Params[pkey] = pvalue
+				}
+				s = skipSpace(s)
+			}
+			specs = append(specs, spec)
+			if !strings.HasPrefix(s, ",") {
+				continue loop
+			}
+			s = skipSpace(s[1:])
+		}
+	}
+	return
+}
+
 func skipSpace(s string) (rest string) {
 	i := 0
 	for ; i < len(s); i++ {
@@ -137,3 +137,34 @@ func TestParseAccept(t *testing.T) {
 		}
 	}
 }
+
+var parseAcceptParamsTests = []struct {
+	s        string
+	expected []AcceptParamsSpec
+}{
+	{"text/html", []AcceptParamsSpec{{AcceptSpec{"text/html", 1}, map[string]string{}}}},
+	{"text/html; q=0.5", []AcceptParamsSpec{{AcceptSpec{"text/html", 0.5}, map[string]string{}}}},
+	{"application/json; pretty=true", []AcceptParamsSpec{{AcceptSpec{"application/json", 1}, map[string]string{"pretty": "true"}}}},
+	{`text/html; Level="1"; q=0.8, text/*;q=0.1`, []AcceptParamsSpec{
+		{AcceptSpec{"text/html", 0.8}, map[string]string{"level": "1"}},
+		{AcceptSpec{"text/*", 0.1}, map[string]string{}},
+	}},
+	{"application/json;pretty=1;q=0.9, */*", []AcceptParamsSpec{
+		{AcceptSpec{"application/json", 0.9}, map[string]string{"pretty": "1"}},
+		{AcceptSpec{"*/*", 1}, map[string]string{}},
+	}},
+
+	// bad cases
+	{"da, en-gb;q=foo", []AcceptParamsSpec{{AcceptSpec{"da", 1}, map[string]string{}}}},
+	{"da, en-gb;level", []AcceptParamsSpec{{AcceptSpec{"da", 1}, map[string]string{}}}},
+}
+
+func TestParseAcceptParams(t *testing.T) {
+	for _, tt := range parseAcceptParamsTests {
+		header := http.Header{"Accept": {tt.s}}
+		actual := ParseAcceptParams(header, "Accept")
+		if !cmp.Equal(actual, tt.expected) {
+			t.Errorf("ParseAcceptParams(h, %q)=%v, want %v", tt.s, actual, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Claims are the registered claims of a verified token. Use Unmarshal to
+// decode custom claims into your own type.
+type Claims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+
+	raw json.RawMessage
+}
+
+// Unmarshal decodes the token's payload into dst, so that services can access
+// custom claims with their own types.
+func (c *Claims) Unmarshal(dst any) error {
+	if len(c.raw) == 0 {
+		return fmt.Errorf("claims do not contain a raw payload")
+	}
+	return json.Unmarshal(c.raw, dst)
+}
+
+// Raw returns the token's JSON payload.
+func (c *Claims) Raw() json.RawMessage {
+	return c.raw
+}
+
+// Scopes returns the granted scopes of the space delimited "scope" claim (RFC
+// 8693) and the "scp" claim, which some issuers send as an array instead.
+func (c *Claims) Scopes() []string {
+	var v struct {
+		Scope stringList `json:"scope"`
+		Scp   stringList `json:"scp"`
+	}
+	if err := c.Unmarshal(&v); err != nil {
+		return nil
+	}
+	return append(v.Scope, v.Scp...)
+}
+
+// Roles returns the roles of the "roles" claim.
+func (c *Claims) Roles() []string {
+	var v struct {
+		Roles stringList `json:"roles"`
+	}
+	if err := c.Unmarshal(&v); err != nil {
+		return nil
+	}
+	return v.Roles
+}
+
+// stringList is a claim that is either an array of strings or a single space
+// delimited string.
+type stringList []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var delimited string
+	if err := json.Unmarshal(data, &delimited); err == nil {
+		*l = strings.Fields(delimited)
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("claim must be a string or an array of strings")
+	}
+	*l = list
+	return nil
+}
+
+// Audience is the "aud" claim, which may either be a single string or an array
+// of strings.
+type Audience []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("audience must be a string or an array of strings")
+	}
+	*a = multiple
+	return nil
+}
+
+// Contains returns true if the audience contains aud.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// NumericDate is a JSON numeric date value as used by the "exp", "nbf" and
+// "iat" claims: the number of seconds since the Unix epoch.
+type NumericDate struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *NumericDate) UnmarshalJSON(data []byte) error {
+	f, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("numeric date must be a number: %w", err)
+	}
+	sec, frac := math.Modf(f)
+	d.Time = time.Unix(int64(sec), int64(frac*1e9))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(d.Unix(), 10)), nil
+}
+
+type claimsCtxKey struct{}
+
+// ContextWithClaims returns a copy of ctx that carries the verified claims.
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsCtxKey{}, claims)
+}
+
+// ClaimsFromContext returns the verified claims that have been put on the
+// context by the authentication middleware. It returns nil if the request has
+// not been authenticated.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsCtxKey{}).(*Claims)
+	return claims
+}
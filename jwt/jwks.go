@@ -0,0 +1,308 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJWKSRefreshInterval is the interval in which a JWKS is reloaded.
+	DefaultJWKSRefreshInterval = time.Hour
+	// DefaultJWKSMinRefreshInterval is the minimum time between two reloads that
+	// are triggered by tokens with an unknown key ID.
+	DefaultJWKSMinRefreshInterval = time.Minute
+	// DefaultJWKSLoadTimeout is the time after which a reload is aborted.
+	DefaultJWKSLoadTimeout = 10 * time.Second
+)
+
+// JWKS is a KeySet backed by a JSON Web Key Set document that is loaded from a
+// file or URL. The keys are cached and reloaded periodically in the
+// background. Tokens signed with an unknown key ID trigger a reload, so that
+// rotated keys are picked up immediately. If reloading fails, the previously
+// loaded keys are used. Keys that cannot be used are skipped and logged.
+type JWKS struct {
+	// RefreshInterval is the interval in which the keys are reloaded.
+	RefreshInterval time.Duration
+	// MinRefreshInterval rate limits reloads triggered by unknown key IDs.
+	MinRefreshInterval time.Duration
+	// LoadTimeout is the time after which a reload is aborted. Reloads are
+	// independent of the requests that trigger them.
+	LoadTimeout time.Duration
+	// Logger logs keys that are skipped and failed reloads.
+	Logger *slog.Logger
+
+	load func(ctx context.Context) ([]byte, error)
+	// allowSymmetric accepts "oct" keys, which must never be published
+	allowSymmetric bool
+
+	mu       sync.Mutex
+	keys     []Key
+	loadErr  error
+	loadedAt time.Time
+	// loading is closed when the running reload has completed
+	loading chan struct{}
+}
+
+// NewRemoteJWKS creates a JWKS that is fetched from the URL. If client is nil
+// a client with a 10s timeout is used. Symmetric ("oct") keys are skipped,
+// because everybody who can read the URL could forge tokens with them.
+func NewRemoteJWKS(url string, client *http.Client) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return newJWKS(false, func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch JWKS: unexpected status code %d", res.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	})
+}
+
+// NewFileJWKS creates a JWKS that is read from a file. Unlike remote key sets
+// it may contain symmetric ("oct") keys.
+func NewFileJWKS(path string) *JWKS {
+	return newJWKS(true, func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	})
+}
+
+func newJWKS(allowSymmetric bool, load func(ctx context.Context) ([]byte, error)) *JWKS {
+	return &JWKS{
+		RefreshInterval:    DefaultJWKSRefreshInterval,
+		MinRefreshInterval: DefaultJWKSMinRefreshInterval,
+		LoadTimeout:        DefaultJWKSLoadTimeout,
+		Logger:             slog.Default(),
+		load:               load,
+		allowSymmetric:     allowSymmetric,
+	}
+}
+
+// Keys implements KeySet. Cached keys are returned right away while they are
+// reloaded in the background. Only if the key ID is unknown (or no keys have
+// been loaded yet) it waits for the reload, or until ctx is done.
+func (j *JWKS) Keys(ctx context.Context, kid string) ([]Key, error) {
+	j.mu.Lock()
+	sinceLoad := time.Since(j.loadedAt)
+	keys := j.keys
+	known := keys != nil && (kid == "" || len(filterKeys(keys, kid)) > 0)
+
+	var loading chan struct{}
+	switch {
+	case j.loading != nil:
+		loading = j.loading
+	case keys == nil && j.loadErr == nil, sinceLoad > j.RefreshInterval:
+		loading = j.reload()
+	case !known && sinceLoad > j.MinRefreshInterval:
+		// The key may have been rotated
+		loading = j.reload()
+	}
+	loadErr := j.loadErr
+	j.mu.Unlock()
+
+	if known || loading == nil {
+		if keys == nil && loadErr != nil {
+			return nil, loadErr
+		}
+		return filterKeys(keys, kid), nil
+	}
+
+	select {
+	case <-loading:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.keys == nil && j.loadErr != nil {
+		return nil, j.loadErr
+	}
+	return filterKeys(j.keys, kid), nil
+}
+
+// reload starts loading the keys in the background, unless a reload is
+// running already. It must be called with j.mu held.
+func (j *JWKS) reload() chan struct{} {
+	if j.loading != nil {
+		return j.loading
+	}
+	// Failed loads count as refresh as well, so that an unavailable JWKS
+	// endpoint is not hammered by every request.
+	j.loadedAt = time.Now()
+	loading := make(chan struct{})
+	j.loading = loading
+
+	go func() {
+		defer close(loading)
+		ctx, cancel := context.WithTimeout(context.Background(), j.LoadTimeout)
+		defer cancel()
+
+		keys, err := j.fetch(ctx)
+		if err != nil {
+			j.Logger.Warn("failed to load JWKS, using the previously loaded keys", slog.Any("error", err))
+		}
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.loading = nil
+		j.loadErr = err
+		if err == nil {
+			j.keys = keys
+		}
+	}()
+	return loading
+}
+
+func (j *JWKS) fetch(ctx context.Context) ([]Key, error) {
+	data, err := j.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data, j.allowSymmetric, j.Logger)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JSON Web Key Set document. Keys that are not used for
+// signatures, that have an unsupported type or that are malformed are skipped
+// and logged with the default logger. Symmetric ("oct") keys are included, so
+// only use it for trusted documents.
+func ParseJWKS(data []byte) ([]Key, error) {
+	return parseJWKS(data, true, slog.Default())
+}
+
+func parseJWKS(data []byte, allowSymmetric bool, logger *slog.Logger) ([]Key, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make([]Key, 0, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if jwk.Kty == "oct" && !allowSymmetric {
+			logger.Warn("skipping symmetric key in JWKS, because it must not be published", slog.String("kid", jwk.Kid))
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			logger.Warn("skipping malformed key in JWKS", slog.String("kid", jwk.Kid), slog.Any("error", err))
+			continue
+		}
+		if key == nil {
+			logger.Debug("skipping key with unsupported type in JWKS", slog.String("kid", jwk.Kid), slog.String("kty", jwk.Kty), slog.String("crv", jwk.Crv))
+			continue
+		}
+		keys = append(keys, Key{ID: jwk.Kid, Algorithm: jwk.Alg, Key: key})
+	}
+	return keys, nil
+}
+
+// publicKey returns the key or nil if the key type is not supported.
+func (k jsonWebKey) publicKey() (any, error) {
+	decode := func(name string, value string) ([]byte, error) {
+		if value == "" {
+			return nil, fmt.Errorf("parameter %q is missing", name)
+		}
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q is not base64url encoded: %w", name, err)
+		}
+		return b, nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent is too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		// Converting the key validates that the point is on the curve
+		if _, err := key.ECDH(); err != nil {
+			return nil, fmt.Errorf("invalid P-256 point: %w", err)
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := decode("k", k.K)
+		if err != nil {
+			return nil, err
+		}
+		return secret, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,165 @@
+// Package jwt verifies JSON Web Tokens signed with HS256, RS256, ES256 or
+// EdDSA against static keys or a JSON Web Key Set.
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultClockSkew is the leeway that is granted when validating the time based
+// claims to account for clock differences between issuer and verifier.
+const DefaultClockSkew = time.Minute
+
+// Errors returned by Verify. They are wrapped, so use errors.Is to check them.
+var (
+	ErrMalformed            = errors.New("token is malformed")
+	ErrUnsupportedAlgorithm = errors.New("token signing algorithm is not supported")
+	ErrUnknownKey           = errors.New("no key found for token")
+	ErrInvalidSignature     = errors.New("token signature is invalid")
+	ErrExpired              = errors.New("token is expired")
+	ErrNotValidYet          = errors.New("token is not valid yet")
+	ErrInvalidIssuer        = errors.New("token issuer is invalid")
+	ErrInvalidAudience      = errors.New("token audience is invalid")
+)
+
+// Config for a Verifier.
+type Config struct {
+	// Keys provides the keys that signatures are verified with.
+	Keys KeySet
+	// Algorithms are the accepted signing algorithms. All supported algorithms
+	// are accepted if it is empty.
+	Algorithms []string
+	// Issuer is the expected "iss" claim. It is not validated if it is empty.
+	Issuer string
+	// Audience is the "aud" claim that tokens must contain. It is not
+	// validated if it is empty.
+	Audience string
+	// ClockSkew is the leeway for validating "exp" and "nbf".
+	ClockSkew time.Duration
+	// AllowMissingExpiration accepts tokens without "exp" claim, which are
+	// valid forever. Tokens without "exp" claim are rejected by default.
+	AllowMissingExpiration bool
+}
+
+// SetDefaults sets the default clock skew.
+func (c *Config) SetDefaults() {
+	c.ClockSkew = DefaultClockSkew
+}
+
+// Verifier verifies tokens and validates their claims.
+type Verifier struct {
+	cfg Config
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewVerifier creates a new Verifier.
+func NewVerifier(cfg Config) *Verifier {
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{HS256, RS256, ES256, EdDSA}
+	}
+	return &Verifier{cfg: cfg, now: time.Now}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// Verify verifies the token's signature, validates its claims and returns
+// them.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: token must consist of three parts", ErrMalformed)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode header: %w", ErrMalformed, err)
+	}
+	if !slices.Contains(v.cfg.Algorithms, h.Alg) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, h.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode signature: %w", ErrMalformed, err)
+	}
+
+	keys, err := v.cfg.Keys.Keys(ctx, h.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keys: %w", err)
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+	hasCandidate := false
+	verified := false
+	for _, key := range keys {
+		if !key.supportsAlgorithm(h.Alg) {
+			continue
+		}
+		hasCandidate = true
+		if key.verify(h.Alg, signingInput, signature) {
+			verified = true
+			break
+		}
+	}
+	if !hasCandidate {
+		return nil, fmt.Errorf("%w: kid %q, alg %q", ErrUnknownKey, h.Kid, h.Alg)
+	}
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode payload: %w", ErrMalformed, err)
+	}
+	claims := &Claims{raw: payload}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode claims: %w", ErrMalformed, err)
+	}
+
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// validate validates the registered claims.
+func (v *Verifier) validate(claims *Claims) error {
+	now := v.now()
+
+	if claims.ExpiresAt == nil && !v.cfg.AllowMissingExpiration {
+		return fmt.Errorf("%w: exp claim is missing", ErrExpired)
+	}
+	if claims.ExpiresAt != nil && !now.Before(claims.ExpiresAt.Add(v.cfg.ClockSkew)) {
+		return fmt.Errorf("%w: expired at %v", ErrExpired, claims.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if claims.NotBefore != nil && now.Add(v.cfg.ClockSkew).Before(claims.NotBefore.Time) {
+		return fmt.Errorf("%w: valid from %v", ErrNotValidYet, claims.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if v.cfg.Issuer != "" && claims.Issuer != v.cfg.Issuer {
+		return fmt.Errorf("%w: %q", ErrInvalidIssuer, claims.Issuer)
+	}
+	if v.cfg.Audience != "" && !claims.Audience.Contains(v.cfg.Audience) {
+		return fmt.Errorf("%w: %q", ErrInvalidAudience, []string(claims.Audience))
+	}
+	return nil
+}
+
+func decodeSegment(segment string, dst any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
@@ -0,0 +1,297 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sign creates a token for tests. The signing key must match the algorithm.
+func sign(t *testing.T, alg string, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	h := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		h["kid"] = kid
+	}
+	headerJSON, err := json.Marshal(h)
+	require.NoError(t, err)
+	claimsJSON, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signingInput))
+	default:
+		t.Fatalf("unsupported key type %T", key)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("secret")
+
+	verifier := NewVerifier(Config{Keys: StaticKeys{
+		{ID: "hmac", Key: secret},
+		{ID: "rsa", Key: &rsaKey.PublicKey},
+		{ID: "ec", Key: &ecKey.PublicKey},
+		{ID: "ed", Key: edPub},
+	}, AllowMissingExpiration: true})
+
+	tests := []struct {
+		alg string
+		kid string
+		key any
+	}{
+		{HS256, "hmac", secret},
+		{RS256, "rsa", rsaKey},
+		{ES256, "ec", ecKey},
+		{EdDSA, "ed", edKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			token := sign(t, tt.alg, tt.kid, tt.key, map[string]any{"sub": "alice", "role": "admin"})
+			claims, err := verifier.Verify(context.Background(), token)
+			require.NoError(t, err)
+			assert.Equal(t, "alice", claims.Subject)
+
+			var custom struct {
+				Role string `json:"role"`
+			}
+			require.NoError(t, claims.Unmarshal(&custom))
+			assert.Equal(t, "admin", custom.Role)
+
+			// Payload of another token with the original signature
+			other := strings.Split(sign(t, tt.alg, tt.kid, tt.key, map[string]any{"sub": "mallory"}), ".")
+			parts := strings.Split(token, ".")
+			_, err = verifier.Verify(context.Background(), parts[0]+"."+other[1]+"."+parts[2])
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		})
+	}
+
+	t.Run("algorithm confusion", func(t *testing.T) {
+		// HS256 token signed with the RSA key id must not be verified with the
+		// public key as HMAC secret
+		token := sign(t, HS256, "rsa", []byte("whatever"), map[string]any{"sub": "mallory"})
+		_, err := verifier.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		token := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory"}`)) + "."
+		_, err := verifier.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	})
+}
+
+func TestVerifyClaims(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2023, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	cfg := Config{Keys: StaticKeys{{Key: secret}}, Issuer: "https://issuer", Audience: "api"}
+	cfg.SetDefaults()
+	verifier := NewVerifier(cfg)
+	verifier.now = func() time.Time { return now }
+
+	valid := map[string]any{
+		"iss": "https://issuer",
+		"aud": []string{"other", "api"},
+		"exp": now.Add(time.Minute).Unix(),
+		"nbf": now.Unix(),
+	}
+	with := func(key string, value any) map[string]any {
+		claims := map[string]any{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name        string
+		claims      map[string]any
+		expectedErr error
+	}{
+		{"valid", valid, nil},
+		{"single audience", with("aud", "api"), nil},
+		{"expired within clock skew", with("exp", now.Add(-30*time.Second).Unix()), nil},
+		{"expired", with("exp", now.Add(-2*time.Minute).Unix()), ErrExpired},
+		{"missing exp", with("exp", nil), ErrExpired},
+		{"not valid yet", with("nbf", now.Add(2*time.Minute).Unix()), ErrNotValidYet},
+		{"wrong issuer", with("iss", "https://evil"), ErrInvalidIssuer},
+		{"wrong audience", with("aud", "other"), ErrInvalidAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), sign(t, HS256, "", secret, tt.claims))
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+
+	// Tokens without "exp" claim must be allowed explicitly
+	cfg.AllowMissingExpiration = true
+	verifier = NewVerifier(cfg)
+	verifier.now = func() time.Time { return now }
+	_, err := verifier.Verify(context.Background(), sign(t, HS256, "", secret, with("exp", nil)))
+	assert.NoError(t, err)
+}
+
+// ecJWK returns the JSON Web Key of the public key.
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.FillBytes(make([]byte, 32))) }
+	return map[string]string{"kty": "EC", "crv": "P-256", "kid": kid, "use": "sig", "x": encode(key.X), "y": encode(key.Y)}
+}
+
+func TestRemoteJWKSRotation(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var rotated atomic.Bool
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keys := []map[string]string{ecJWK("old", oldKey)}
+		if rotated.Load() {
+			keys = []map[string]string{ecJWK("new", newKey)}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	defer srv.Close()
+
+	jwks := NewRemoteJWKS(srv.URL, srv.Client())
+	jwks.MinRefreshInterval = 0
+	verifier := NewVerifier(Config{Keys: jwks, AllowMissingExpiration: true})
+
+	_, err = verifier.Verify(context.Background(), sign(t, ES256, "old", oldKey, map[string]any{"sub": "alice"}))
+	require.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), sign(t, ES256, "old", oldKey, map[string]any{"sub": "alice"}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, fetches.Load(), "keys must be cached")
+
+	rotated.Store(true)
+	_, err = verifier.Verify(context.Background(), sign(t, ES256, "new", newKey, map[string]any{"sub": "alice"}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, fetches.Load(), "unknown kid must trigger a refresh")
+
+	_, err = verifier.Verify(context.Background(), sign(t, ES256, "unknown", newKey, map[string]any{"sub": "alice"}))
+	assert.True(t, errors.Is(err, ErrUnknownKey), fmt.Sprintf("unexpected error: %v", err))
+}
+
+func TestRemoteJWKSSkipsUnusableKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "EC", "crv": "P-256", "kid": "malformed", "x": "!", "y": "!"},
+			{"kty": "EC", "crv": "P-521", "kid": "unsupported"},
+			{"kty": "oct", "kid": "symmetric", "k": base64.RawURLEncoding.EncodeToString(secret)},
+			ecJWK("ok", key),
+		}})
+	}))
+	defer srv.Close()
+
+	keys, err := NewRemoteJWKS(srv.URL, srv.Client()).Keys(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "ok", keys[0].ID)
+
+	// Symmetric keys published at a URL could be used by anybody to forge tokens
+	verifier := NewVerifier(Config{Keys: NewRemoteJWKS(srv.URL, srv.Client()), AllowMissingExpiration: true})
+	_, err = verifier.Verify(context.Background(), sign(t, HS256, "symmetric", secret, map[string]any{"sub": "alice"}))
+	assert.True(t, errors.Is(err, ErrUnknownKey), fmt.Sprintf("unexpected error: %v", err))
+}
+
+func TestRemoteJWKSReloadsInBackground(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var fetches atomic.Int32
+	unblock := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-unblock
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{ecJWK("key", key)}})
+	}))
+	defer srv.Close()
+	defer close(unblock)
+
+	jwks := NewRemoteJWKS(srv.URL, srv.Client())
+	keys, err := jwks.Keys(context.Background(), "key")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	// The hanging reload neither blocks requests with a known key ID nor is it
+	// started twice
+	jwks.RefreshInterval = 0
+	for range 3 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		keys, err = jwks.Keys(ctx, "key")
+		cancel()
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+	}
+	assert.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	// Unknown key IDs wait for the reload until their context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = jwks.Keys(ctx, "unknown")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
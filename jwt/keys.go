@@ -0,0 +1,146 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Supported signing algorithms.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+// Key is a key that token signatures are verified with.
+type Key struct {
+	// ID is matched against the token's "kid" header. Keys without ID match all
+	// tokens.
+	ID string
+	// Algorithm restricts the key to a single algorithm. If it is empty the
+	// algorithm is derived from the key type.
+	Algorithm string
+	// Key is a []byte secret for HS256, an *rsa.PublicKey for RS256, an
+	// *ecdsa.PublicKey (P-256) for ES256 or an ed25519.PublicKey for EdDSA.
+	Key any
+}
+
+// KeySet provides the keys that tokens are verified with.
+type KeySet interface {
+	// Keys returns the keys that may have been used to sign a token with the
+	// given key ID. The key ID is empty if the token has no "kid" header.
+	Keys(ctx context.Context, kid string) ([]Key, error)
+}
+
+// StaticKeys is a KeySet with a fixed list of keys.
+type StaticKeys []Key
+
+// Keys implements KeySet.
+func (s StaticKeys) Keys(_ context.Context, kid string) ([]Key, error) {
+	return filterKeys(s, kid), nil
+}
+
+// filterKeys returns all keys that match the key ID.
+func filterKeys(keys []Key, kid string) []Key {
+	var matching []Key
+	for _, key := range keys {
+		if kid == "" || key.ID == "" || key.ID == kid {
+			matching = append(matching, key)
+		}
+	}
+	return matching
+}
+
+// supportsAlgorithm returns true if the key can verify signatures of the given
+// algorithm.
+func (k Key) supportsAlgorithm(alg string) bool {
+	if k.Algorithm != "" && k.Algorithm != alg {
+		return false
+	}
+	switch key := k.Key.(type) {
+	case []byte:
+		return alg == HS256
+	case *rsa.PublicKey:
+		return alg == RS256
+	case *ecdsa.PublicKey:
+		return alg == ES256 && key.Curve == elliptic.P256()
+	case ed25519.PublicKey:
+		return alg == EdDSA
+	default:
+		return false
+	}
+}
+
+// verify verifies the signature of the signing input with the key.
+func (k Key) verify(alg string, signingInput []byte, signature []byte) bool {
+	if !k.supportsAlgorithm(alg) {
+		return false
+	}
+
+	switch key := k.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signingInput)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signingInput)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS encodes ECDSA signatures as fixed size R || S instead of ASN.1
+		if len(signature) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(signingInput)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, signingInput, signature)
+	default:
+		return false
+	}
+}
+
+// ParsePublicKeyPEM parses a PEM encoded RSA, ECDSA or Ed25519 public key. PKIX
+// public keys, PKCS #1 RSA public keys and X.509 certificates are supported.
+func ParsePublicKeyPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+		}
+		return key, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
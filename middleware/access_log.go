@@ -1,52 +1,267 @@
 package middleware
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/cloudhut/common/rest"
 )
 
+// DefaultRedactedHeaders are the headers whose values are never logged as long
+// as AccessLogOptions.RedactHeaders is not set.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// redactedValue replaces the values of redacted headers.
+const redactedValue = "[REDACTED]"
+
+// remoteClientIDHeader identifies the client, see AccessLogOptions.RemoteClientID.
+const remoteClientIDHeader = "remoteClientId"
+
+// AccessLogOptions selects the fields of the access log entries.
+type AccessLogOptions struct {
+	// RequestHeaders and ResponseHeaders are the headers that are logged in the
+	// groups "request_headers" and "response_headers", keyed by the lower case
+	// header name. Headers that are not present are omitted.
+	RequestHeaders  []string
+	ResponseHeaders []string
+	// RedactHeaders are logged with a redacted value. It defaults to
+	// DefaultRedactedHeaders.
+	RedactHeaders []string
+
+	UserAgent bool
+	Referer   bool
+	// RequestID logs the request ID set by chi's RequestID middleware or, if
+	// absent, the X-Request-Id header.
+	RequestID bool
+	// RoutePattern logs the chi route pattern of the request, e.g.
+	// /topics/{topicName}.
+	RoutePattern bool
+	// Principal logs the authenticated principal which the Authenticator or
+	// SetAccessLogPrincipal has set.
+	Principal bool
+	// RemoteClientID logs the value of the remoteClientId request header as
+	// remote_client_id, which NewAccessLog has always logged.
+	RemoteClientID bool
+
+	// LevelByStatusClass maps status classes (e.g. 4 for 4xx responses) to the
+	// level of their log entries. Status classes without a level are logged
+	// with slog.LevelInfo.
+	LevelByStatusClass map[int]slog.Level
+
+	// SkipPaths are not logged, e.g. health probes. Paths ending with a slash
+	// skip all paths below them.
+	SkipPaths []string
+
+	// Format selects the field names and units of the access log entries. It
+	// defaults to AccessLogFormatDefault.
+	Format AccessLogFormat
+
+	// Sampling reduces the volume of access logs for successful requests. All
+	// requests are logged if it is nil.
+	Sampling *AccessLogSampling
+}
+
 // AccessLog implements the middleware interface
 type AccessLog struct {
 	logger *slog.Logger
+	opts   AccessLogOptions
+
+	names    accessLogFieldNames
+	redacted map[string]struct{}
+	sampler  *accessLogSampler
 }
 
-// NewAccessLog creates a new middleware which prints access logs
+// NewAccessLog creates a new middleware which prints access logs including the
+// remote_client_id field. The value of extraHeader, if not empty, is logged
+// alongside the default fields.
 func NewAccessLog(logger *slog.Logger, extraHeader string) *AccessLog {
-	return &AccessLog{logger}
+	opts := AccessLogOptions{RemoteClientID: true}
+	if extraHeader != "" {
+		opts.RequestHeaders = []string{extraHeader}
+	}
+	return NewAccessLogWithOptions(logger, opts)
+}
+
+// NewAccessLogWithOptions creates a new middleware which prints access logs
+// with the fields selected by opts.
+func NewAccessLogWithOptions(logger *slog.Logger, opts AccessLogOptions) *AccessLog {
+	redactHeaders := opts.RedactHeaders
+	if redactHeaders == nil {
+		redactHeaders = DefaultRedactedHeaders
+	}
+	redacted := make(map[string]struct{}, len(redactHeaders))
+	for _, header := range redactHeaders {
+		redacted[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+
+	var sampler *accessLogSampler
+	if opts.Sampling != nil {
+		sampler = newAccessLogSampler(*opts.Sampling)
+	}
+
+	return &AccessLog{
+		logger:   logger,
+		opts:     opts,
+		names:    fieldNamesByFormat[opts.Format],
+		redacted: redacted,
+		sampler:  sampler,
+	}
 }
 
 // Wrap implements the middleware interface
 func (a *AccessLog) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rest.MatchPath(a.opts.SkipPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		start := time.Now()
+		entry := &accessLogEntry{}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogEntryCtxKey{}, entry))
+		ww := wrapResponseWriter(w, r)
+
+		// Re-panics after recording status 500, see NewChain
+		defer func() {
+			if err := recover(); err != nil {
+				a.log(r, ww, http.StatusInternalServerError, start, entry)
+				panic(err)
+			}
+		}()
 
 		// Call next handler
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(ww, r)
+
+		a.log(r, ww, ww.Status(), start, entry)
+	})
+}
+
+// log prints the access log entry of the request unless it is sampled out.
+func (a *AccessLog) log(r *http.Request, ww middleware.WrapResponseWriter, status int, start time.Time, entry *accessLogEntry) {
+	duration := time.Since(start)
+	if a.sampler != nil && !a.sampler.keep(r, status, duration) {
+		return
+	}
+	if a.opts.Format == AccessLogFormatCombined || a.opts.Format == AccessLogFormatCommon {
+		line := combinedLogLine(r, ww, status, start, entry.getPrincipal(), a.opts.Format == AccessLogFormatCombined)
+		a.logger.LogAttrs(r.Context(), a.level(status), line, slog.String("log_type", "access"))
+		return
+	}
+
+	attrs := a.fieldAttrs(r, ww, status, duration)
+	attrs = append(attrs, a.optionalAttrs(r, ww, entry)...)
+
+	a.logger.LogAttrs(r.Context(), a.level(status), "http request", attrs...)
+}
 
-		ww, ok := w.(middleware.WrapResponseWriter)
-		if !ok {
-			panic("could not convert to middleware.WrapResponseWriter")
+// optionalAttrs returns the attributes which have been selected in the options.
+func (a *AccessLog) optionalAttrs(r *http.Request, w http.ResponseWriter, entry *accessLogEntry) []slog.Attr {
+	var attrs []slog.Attr
+	if a.opts.UserAgent {
+		attrs = append(attrs, slog.String(a.names.userAgent, r.UserAgent()))
+	}
+	if a.opts.Referer {
+		attrs = append(attrs, slog.String(a.names.referer, r.Referer()))
+	}
+	if a.opts.RequestID {
+		requestID := middleware.GetReqID(r.Context())
+		if requestID == "" {
+			requestID = r.Header.Get(middleware.RequestIDHeader)
 		}
+		attrs = append(attrs, slog.String(a.names.requestID, requestID))
+	}
+	if a.opts.RoutePattern {
+		attrs = append(attrs, slog.String(a.names.route, getRoutePattern(r)))
+	}
+	if a.opts.Principal {
+		attrs = append(attrs, slog.String(a.names.principal, entry.getPrincipal()))
+	}
+	if a.opts.RemoteClientID {
+		attrs = append(attrs, slog.String(a.names.remoteClientID, r.Header.Get(remoteClientIDHeader)))
+	}
+	attrs = append(attrs, a.headersAttrs(a.names.requestHeaders, r.Header, a.opts.RequestHeaders)...)
+	attrs = append(attrs, a.headersAttrs(a.names.responseHeaders, w.Header(), a.opts.ResponseHeaders)...)
+	return attrs
+}
 
-		duration := time.Since(start)
-		durationMs := duration.Nanoseconds() / (1000 * 1000)
-
-		a.logger.InfoContext(r.Context(), "http request",
-			slog.String("log_type", "access"),
-			slog.String("remote_address", r.RemoteAddr),
-			slog.Int64("response_time", durationMs),
-			slog.String("protocol", r.Proto),
-			slog.String("request_method", r.Method),
-			slog.String("query_string", r.URL.RawQuery),
-			slog.String("status", strconv.Itoa(ww.Status())),
-			slog.String("uri", r.URL.Path),
-			slog.String("server_name", r.URL.Host),
-			slog.Int64("bytes_received", r.ContentLength),
-			slog.Int("bytes_sent", ww.BytesWritten()),
-			slog.String("remote_client_id", r.Header.Get("remoteClientId")))
-	})
+// headersAttrs returns the present headers in a group with the given key or,
+// if the format flattens headers, as attributes prefixed with the key.
+func (a *AccessLog) headersAttrs(key string, header http.Header, names []string) []slog.Attr {
+	attrs := a.headerAttrs(header, names)
+	if len(attrs) == 0 {
+		return nil
+	}
+	if !a.names.flattenHeaders {
+		return []slog.Attr{{Key: key, Value: slog.GroupValue(attrs...)}}
+	}
+	for i := range attrs {
+		attrs[i].Key = key + "." + attrs[i].Key
+	}
+	return attrs
+}
+
+// headerAttrs returns the values of the present headers, redacting sensitive
+// ones.
+func (a *AccessLog) headerAttrs(header http.Header, names []string) []slog.Attr {
+	var attrs []slog.Attr
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if _, ok := a.redacted[http.CanonicalHeaderKey(name)]; ok {
+			value = redactedValue
+		}
+		attrs = append(attrs, slog.String(strings.ToLower(name), value))
+	}
+	return attrs
+}
+
+// level returns the log level for the status code's class.
+func (a *AccessLog) level(status int) slog.Level {
+	if level, ok := a.opts.LevelByStatusClass[status/100]; ok {
+		return level
+	}
+	return slog.LevelInfo
+}
+
+type accessLogEntryCtxKey struct{}
+
+// accessLogEntry collects fields which are only known to handlers further down
+// the chain, whose request contexts the access log cannot see.
+type accessLogEntry struct {
+	mu        sync.Mutex
+	principal string
+}
+
+func (e *accessLogEntry) getPrincipal() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.principal
+}
+
+// SetAccessLogPrincipal sets the authenticated principal that the AccessLog
+// logs for the request. The Authenticator sets the subject of the verified
+// token; call it from custom authentication middlewares.
+func SetAccessLogPrincipal(ctx context.Context, principal string) {
+	entry, ok := ctx.Value(accessLogEntryCtxKey{}).(*accessLogEntry)
+	if !ok {
+		return
+	}
+	entry.mu.Lock()
+	entry.principal = principal
+	entry.mu.Unlock()
 }
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AccessLogFormat is the schema of the access log entries.
+type AccessLogFormat int
+
+const (
+	// AccessLogFormatDefault logs the fields this package has always logged,
+	// e.g. response_time in milliseconds and status as a string.
+	AccessLogFormatDefault AccessLogFormat = iota
+	// AccessLogFormatCombined logs the request in the Apache Combined Log
+	// Format as message, for tooling that parses web server logs. Optional
+	// fields other than the user agent, referer and principal are not logged.
+	AccessLogFormatCombined
+	// AccessLogFormatECS uses the field names of the Elastic Common Schema
+	// (ECS), e.g. http.response.status_code and event.duration in nanoseconds.
+	AccessLogFormatECS
+	// AccessLogFormatOTel uses the attribute names of the OpenTelemetry HTTP
+	// semantic conventions, e.g. http.response.status_code. The conventions
+	// define no log attribute for the duration, which is logged as duration
+	// in seconds.
+	AccessLogFormatOTel
+	// AccessLogFormatCommon logs the request in the Common Log Format (the
+	// Combined Log Format without referer and user agent) as message. Only the
+	// principal of the optional fields is logged.
+	AccessLogFormatCommon
+)
+
+// combinedLogTimeFormat is the timestamp layout of the Common and Combined Log
+// Formats.
+const combinedLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogFieldNames are the names of the optional fields in a format.
+type accessLogFieldNames struct {
+	userAgent       string
+	referer         string
+	requestID       string
+	route           string
+	principal       string
+	remoteClientID  string
+	requestHeaders  string
+	responseHeaders string
+	// flattenHeaders logs every header as separate attribute, prefixed with
+	// the headers field name, instead of in a group.
+	flattenHeaders bool
+}
+
+var defaultFieldNames = accessLogFieldNames{
+	userAgent:       "user_agent",
+	referer:         "referer",
+	requestID:       "request_id",
+	route:           "route",
+	principal:       "principal",
+	remoteClientID:  "remote_client_id",
+	requestHeaders:  "request_headers",
+	responseHeaders: "response_headers",
+}
+
+var fieldNamesByFormat = map[AccessLogFormat]accessLogFieldNames{
+	AccessLogFormatDefault:  defaultFieldNames,
+	AccessLogFormatCombined: defaultFieldNames,
+	AccessLogFormatCommon:   defaultFieldNames,
+	AccessLogFormatECS: {
+		userAgent:       "user_agent.original",
+		referer:         "http.request.referrer",
+		requestID:       "http.request.id",
+		route:           "http.route",
+		principal:       "user.name",
+		remoteClientID:  "labels.remote_client_id",
+		requestHeaders:  "http.request.headers",
+		responseHeaders: "http.response.headers",
+	},
+	AccessLogFormatOTel: {
+		userAgent:       "user_agent.original",
+		referer:         "http.request.header.referer",
+		requestID:       "http.request.id",
+		route:           "http.route",
+		principal:       "enduser.id",
+		remoteClientID:  "http.request.header.remoteclientid",
+		requestHeaders:  "http.request.header",
+		responseHeaders: "http.response.header",
+		flattenHeaders:  true,
+	},
+}
+
+// fieldAttrs returns the fields that are logged for every request in the
+// configured format.
+func (a *AccessLog) fieldAttrs(r *http.Request, ww middleware.WrapResponseWriter, status int, duration time.Duration) []slog.Attr {
+	switch a.opts.Format {
+	case AccessLogFormatECS:
+		return withRequestBodySize([]slog.Attr{
+			slog.String("event.kind", "event"),
+			slog.String("event.category", "web"),
+			slog.Int64("event.duration", duration.Nanoseconds()),
+			slog.String("client.address", clientAddress(r)),
+			slog.String("http.version", protocolVersion(r)),
+			slog.String("http.request.method", r.Method),
+			slog.Int("http.response.status_code", status),
+			slog.Int("http.response.body.bytes", ww.BytesWritten()),
+			slog.String("url.scheme", scheme(r)),
+			slog.String("url.domain", serverAddress(r)),
+			slog.String("url.path", r.URL.Path),
+			slog.String("url.query", r.URL.RawQuery),
+		}, "http.request.body.bytes", r)
+	case AccessLogFormatOTel:
+		return withRequestBodySize([]slog.Attr{
+			slog.Float64("duration", duration.Seconds()),
+			slog.String("client.address", clientAddress(r)),
+			slog.String("network.protocol.name", "http"),
+			slog.String("network.protocol.version", protocolVersion(r)),
+			slog.String("http.request.method", r.Method),
+			slog.Int("http.response.status_code", status),
+			slog.Int("http.response.body.size", ww.BytesWritten()),
+			slog.String("url.scheme", scheme(r)),
+			slog.String("server.address", serverAddress(r)),
+			slog.String("url.path", r.URL.Path),
+			slog.String("url.query", r.URL.RawQuery),
+		}, "http.request.body.size", r)
+	default:
+		return []slog.Attr{
+			slog.String("log_type", "access"),
+			slog.String("remote_address", r.RemoteAddr),
+			slog.Int64("response_time", duration.Nanoseconds()/(1000*1000)),
+			slog.String("protocol", r.Proto),
+			slog.String("request_method", r.Method),
+			slog.String("query_string", r.URL.RawQuery),
+			slog.String("status", strconv.Itoa(status)),
+			slog.String("uri", r.URL.Path),
+			slog.String("server_name", r.URL.Host),
+			slog.Int64("bytes_received", r.ContentLength),
+			slog.Int("bytes_sent", ww.BytesWritten()),
+		}
+	}
+}
+
+// withRequestBodySize appends the size of the request body, unless it is
+// unknown. The schemas define the size as non-negative number of bytes.
+func withRequestBodySize(attrs []slog.Attr, key string, r *http.Request) []slog.Attr {
+	if r.ContentLength < 0 {
+		return attrs
+	}
+	return append(attrs, slog.Int64(key, r.ContentLength))
+}
+
+// combinedLogLine formats the request in the Apache Combined Log Format or, if
+// combined is false, in the Common Log Format:
+//
+//	host ident authuser [date] "request line" status bytes "referer" "user agent"
+func combinedLogLine(r *http.Request, ww middleware.WrapResponseWriter, status int, start time.Time, principal string, combined bool) string {
+	requestURI := r.RequestURI
+	if requestURI == "" {
+		requestURI = r.URL.RequestURI()
+	}
+	bytesSent := "-"
+	if ww.BytesWritten() > 0 {
+		bytesSent = strconv.Itoa(ww.BytesWritten())
+	}
+
+	var b strings.Builder
+	b.WriteString(clientAddress(r))
+	b.WriteString(" - ")
+	b.WriteString(orDash(principal))
+	b.WriteString(" [")
+	b.WriteString(start.Format(combinedLogTimeFormat))
+	b.WriteString("] ")
+	b.WriteString(strconv.Quote(r.Method + " " + requestURI + " " + r.Proto))
+	b.WriteString(" ")
+	b.WriteString(strconv.Itoa(status))
+	b.WriteString(" ")
+	b.WriteString(bytesSent)
+	if !combined {
+		return b.String()
+	}
+	b.WriteString(" ")
+	b.WriteString(strconv.Quote(orDash(r.Referer())))
+	b.WriteString(" ")
+	b.WriteString(strconv.Quote(orDash(r.UserAgent())))
+	return b.String()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// clientAddress returns the IP address of the client without the port.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// serverAddress returns the host name the request has been sent to.
+func serverAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return r.Host
+	}
+	return host
+}
+
+// protocolVersion returns the HTTP version, e.g. "1.1" or "2".
+func protocolVersion(r *http.Request) string {
+	if r.ProtoMajor >= 2 {
+		return strconv.Itoa(r.ProtoMajor)
+	}
+	return strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)
+}
+
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
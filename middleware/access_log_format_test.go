@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	serve := func(opts AccessLogOptions, modify ...func(r *http.Request)) map[string]any {
+		var buf bytes.Buffer
+		router := newAccessLogRouter(&buf, opts)
+		router.Get("/topics/{topicName}", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("hello"))
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/topics/orders?page=2", nil)
+		req.Host = "example.com:8080"
+		req.RemoteAddr = "10.0.0.1:51234"
+		req.Header.Set("User-Agent", "curl/8.0")
+		for _, m := range modify {
+			m(req)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		entries := decodeLogEntries(t, &buf)
+		require.Len(t, entries, 1)
+		return entries[0]
+	}
+
+	t.Run("combined", func(t *testing.T) {
+		entry := serve(AccessLogOptions{Format: AccessLogFormatCombined})
+		assert.Regexp(t, regexp.MustCompile(`^10\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /topics/orders\?page=2 HTTP/1\.1" 201 5 "-" "curl/8\.0"$`), entry["msg"])
+		assert.Equal(t, "access", entry["log_type"])
+	})
+
+	t.Run("common", func(t *testing.T) {
+		entry := serve(AccessLogOptions{Format: AccessLogFormatCommon})
+		assert.Regexp(t, regexp.MustCompile(`^10\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /topics/orders\?page=2 HTTP/1\.1" 201 5$`), entry["msg"])
+	})
+
+	t.Run("ecs", func(t *testing.T) {
+		entry := serve(AccessLogOptions{Format: AccessLogFormatECS, RoutePattern: true, UserAgent: true, ResponseHeaders: []string{"Content-Type"}})
+		assert.Equal(t, "GET", entry["http.request.method"])
+		assert.Equal(t, 201.0, entry["http.response.status_code"])
+		assert.Equal(t, 5.0, entry["http.response.body.bytes"])
+		assert.Equal(t, "/topics/orders", entry["url.path"])
+		assert.Equal(t, "page=2", entry["url.query"])
+		assert.Equal(t, "example.com", entry["url.domain"])
+		assert.Equal(t, "10.0.0.1", entry["client.address"])
+		assert.Equal(t, "1.1", entry["http.version"])
+		assert.Equal(t, "/topics/{topicName}", entry["http.route"])
+		assert.Equal(t, "curl/8.0", entry["user_agent.original"])
+		assert.Equal(t, map[string]any{"content-type": "text/plain"}, entry["http.response.headers"])
+		assert.IsType(t, 0.0, entry["event.duration"])
+		assert.Equal(t, 0.0, entry["http.request.body.bytes"])
+	})
+
+	t.Run("otel", func(t *testing.T) {
+		entry := serve(AccessLogOptions{Format: AccessLogFormatOTel, RoutePattern: true, ResponseHeaders: []string{"Content-Type"}})
+		assert.Equal(t, "GET", entry["http.request.method"])
+		assert.Equal(t, 201.0, entry["http.response.status_code"])
+		assert.Equal(t, 5.0, entry["http.response.body.size"])
+		assert.Equal(t, "/topics/orders", entry["url.path"])
+		assert.Equal(t, "http", entry["url.scheme"])
+		assert.Equal(t, "example.com", entry["server.address"])
+		assert.Equal(t, "1.1", entry["network.protocol.version"])
+		assert.Equal(t, "/topics/{topicName}", entry["http.route"])
+		assert.Equal(t, "text/plain", entry["http.response.header.content-type"])
+		assert.Less(t, entry["duration"], 1.0)
+		assert.NotContains(t, entry, "http.server.request.duration")
+		assert.Equal(t, 0.0, entry["http.request.body.size"])
+	})
+
+	t.Run("unknown request body size", func(t *testing.T) {
+		unknownLength := func(r *http.Request) { r.ContentLength = -1 }
+		assert.NotContains(t, serve(AccessLogOptions{Format: AccessLogFormatECS}, unknownLength), "http.request.body.bytes")
+		assert.NotContains(t, serve(AccessLogOptions{Format: AccessLogFormatOTel}, unknownLength), "http.request.body.size")
+	})
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AccessLogSampling drops access log entries of successful requests. Failed
+// requests (status code 400 and above) and slow requests are always logged.
+// Dropped entries are counted per route and reason ("sampled" or
+// "rate_limited").
+type AccessLogSampling struct {
+	// SuccessSampleRate is the fraction of successful requests that are
+	// logged, between 0 and 1 (all). Zero is treated as unset and logs all
+	// successful requests, so that rate limits can be used on their own.
+	SuccessSampleRate float64
+	// SlowThreshold is the duration after which requests are always logged.
+	// Zero disables it.
+	SlowThreshold time.Duration
+	// RouteRateLimit caps the entries of successful requests per second and
+	// route pattern after sampling, with bursts of up to RouteBurst entries.
+	// Zero disables the limit.
+	RouteRateLimit float64
+	RouteBurst     int
+	// MetricsNamespace is the namespace of the dropped entries counter.
+	MetricsNamespace string
+	// Registerer registers the dropped entries counter. It defaults to
+	// prometheus.DefaultRegisterer. Access logs with the same namespace share
+	// the counter.
+	Registerer prometheus.Registerer
+}
+
+// accessLogSampler decides which access log entries are dropped.
+type accessLogSampler struct {
+	cfg     AccessLogSampling
+	dropped *prometheus.CounterVec
+	// random returns a number in [0, 1) to sample requests
+	random func() float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// newAccessLogSampler creates a new sampler and registers its dropped entries
+// counter.
+func newAccessLogSampler(cfg AccessLogSampling) *accessLogSampler {
+	dropped := registerCollector(cfg.Registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: cfg.MetricsNamespace,
+		Name:      "access_log_dropped_total",
+		Help:      "Number of access log entries that have been dropped by sampling or rate limiting.",
+	}, []string{"route", "reason"}))
+
+	if cfg.SuccessSampleRate <= 0 {
+		cfg.SuccessSampleRate = 1
+	}
+
+	return &accessLogSampler{
+		cfg:     cfg,
+		dropped: dropped,
+		random:  rand.Float64,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// keep returns true if the access log entry of the request must be logged.
+func (s *accessLogSampler) keep(r *http.Request, status int, duration time.Duration) bool {
+	if status >= http.StatusBadRequest {
+		return true
+	}
+	if s.cfg.SlowThreshold > 0 && duration >= s.cfg.SlowThreshold {
+		return true
+	}
+
+	route := getRoutePattern(r)
+	if s.cfg.SuccessSampleRate < 1 && s.random() >= s.cfg.SuccessSampleRate {
+		s.dropped.WithLabelValues(route, "sampled").Inc()
+		return false
+	}
+	if s.cfg.RouteRateLimit > 0 && !s.bucket(route).take(time.Now()) {
+		s.dropped.WithLabelValues(route, "rate_limited").Inc()
+		return false
+	}
+	return true
+}
+
+// bucket returns the token bucket of the route. The number of buckets is
+// bounded by the number of route patterns.
+func (s *accessLogSampler) bucket(route string) *tokenBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[route]
+	if !ok {
+		burst := float64(max(s.cfg.RouteBurst, 1))
+		bucket = &tokenBucket{rate: s.cfg.RouteRateLimit, burst: burst, tokens: burst, last: time.Now()}
+		s.buckets[route] = bucket
+	}
+	return bucket
+}
+
+// tokenBucket is refilled with rate tokens per second up to burst tokens.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// take removes a token and returns false if the bucket is empty.
+func (b *tokenBucket) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
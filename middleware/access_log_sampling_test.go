@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog := NewAccessLogWithOptions(slog.New(slog.NewJSONHandler(&buf, nil)), AccessLogOptions{
+		RoutePattern: true,
+		Sampling: &AccessLogSampling{
+			SuccessSampleRate: 0.1,
+			SlowThreshold:     50 * time.Millisecond,
+			MetricsNamespace:  "sampling_test",
+		},
+	})
+	accessLog.sampler.random = func() float64 { return 0.5 }
+	router := chi.NewRouter()
+	router.Use(Intercept, accessLog.Wrap)
+	router.Get("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	router.Get("/slow", func(w http.ResponseWriter, r *http.Request) { time.Sleep(60 * time.Millisecond) })
+	router.Get("/fail", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadRequest) })
+
+	for _, target := range []string{"/ok", "/ok", "/slow", "/fail"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	var routes []any
+	for _, entry := range decodeLogEntries(t, &buf) {
+		routes = append(routes, entry["route"])
+	}
+	assert.Equal(t, []any{"/slow", "/fail"}, routes)
+	assert.Equal(t, 2.0, testutil.ToFloat64(accessLog.sampler.dropped.WithLabelValues("/ok", "sampled")))
+}
+
+func TestAccessLogSamplingRouteRateLimit(t *testing.T) {
+	sampler := newAccessLogSampler(AccessLogSampling{
+		SuccessSampleRate: 1,
+		RouteRateLimit:    0.001,
+		RouteBurst:        2,
+		MetricsNamespace:  "rate_limit_test",
+	})
+	req := httptest.NewRequest(http.MethodGet, "/poll", nil)
+
+	var kept int
+	for range 5 {
+		if sampler.keep(req, http.StatusOK, time.Millisecond) {
+			kept++
+		}
+	}
+	assert.Equal(t, 2, kept)
+	assert.True(t, sampler.keep(req, http.StatusServiceUnavailable, time.Millisecond))
+	assert.Equal(t, 3.0, testutil.ToFloat64(sampler.dropped.WithLabelValues("other", "rate_limited")))
+}
+
+func TestAccessLogSamplingRateLimitOnly(t *testing.T) {
+	sampler := newAccessLogSampler(AccessLogSampling{RouteRateLimit: 10, RouteBurst: 3, MetricsNamespace: "rate_limit_only_test"})
+	req := httptest.NewRequest(http.MethodGet, "/poll", nil)
+
+	var kept int
+	for range 3 {
+		if sampler.keep(req, http.StatusOK, time.Millisecond) {
+			kept++
+		}
+	}
+	assert.Equal(t, 3, kept, "unset sample rate must not drop successful requests")
+	assert.Equal(t, 0.0, testutil.ToFloat64(sampler.dropped.WithLabelValues("other", "sampled")))
+}
+
+func TestAccessLogSamplingRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	cfg := AccessLogSampling{SuccessSampleRate: 0.5, MetricsNamespace: "registerer_test", Registerer: registry}
+
+	// Access logs of several routers share the counter instead of panicking
+	first := newAccessLogSampler(cfg)
+	second := newAccessLogSampler(cfg)
+	first.random = func() float64 { return 0.9 }
+	second.random = func() float64 { return 0.9 }
+
+	req := httptest.NewRequest(http.MethodGet, "/poll", nil)
+	assert.False(t, first.keep(req, http.StatusOK, time.Millisecond))
+	assert.False(t, second.keep(req, http.StatusOK, time.Millisecond))
+	assert.Equal(t, 2.0, testutil.ToFloat64(first.dropped.WithLabelValues("other", "sampled")))
+
+	count, err := testutil.GatherAndCount(registry, "registerer_test_access_log_dropped_total")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	bucket := &tokenBucket{rate: 1, burst: 1, tokens: 1, last: start}
+
+	assert.True(t, bucket.take(start))
+	assert.False(t, bucket.take(start.Add(500*time.Millisecond)))
+	assert.True(t, bucket.take(start.Add(time.Second)))
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudhut/common/jwt"
+)
+
+// newAccessLogRouter returns a router that logs all requests as JSON to buf.
+func newAccessLogRouter(buf *bytes.Buffer, opts AccessLogOptions) *chi.Mux {
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	router := chi.NewRouter()
+	router.Use(Intercept)
+	router.Use(NewAccessLogWithOptions(logger, opts).Wrap)
+	return router
+}
+
+func decodeLogEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var entry map[string]any
+		require.NoError(t, decoder.Decode(&entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAccessLogOptions(t *testing.T) {
+	var buf bytes.Buffer
+	secret := []byte("secret")
+	router := newAccessLogRouter(&buf, AccessLogOptions{
+		RequestHeaders:     []string{"X-Client-Id", "authorization", "X-Missing"},
+		ResponseHeaders:    []string{"Content-Type"},
+		UserAgent:          true,
+		Referer:            true,
+		RequestID:          true,
+		RoutePattern:       true,
+		Principal:          true,
+		LevelByStatusClass: map[int]slog.Level{4: slog.LevelWarn},
+		SkipPaths:          []string{"/health/"},
+	})
+	router.Get("/health/ready", func(w http.ResponseWriter, r *http.Request) {})
+	router.With(NewAuthenticator(slog.Default(), jwt.NewVerifier(jwt.Config{Keys: jwt.StaticKeys{{Key: secret}}, AllowMissingExpiration: true})).Wrap).
+		Get("/topics/{topicName}", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/topics/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+hs256Token(secret, `{"sub":"alice"}`))
+	req.Header.Set("X-Client-Id", "client-1")
+	req.Header.Set("X-Request-Id", "req-1")
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.Header.Set("Referer", "https://example.com/")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := decodeLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "404", entry["status"])
+	assert.Equal(t, "curl/8.0", entry["user_agent"])
+	assert.Equal(t, "https://example.com/", entry["referer"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "/topics/{topicName}", entry["route"])
+	assert.Equal(t, "alice", entry["principal"])
+	assert.Equal(t, map[string]any{"x-client-id": "client-1", "authorization": "[REDACTED]"}, entry["request_headers"])
+	assert.Equal(t, map[string]any{"content-type": "application/json"}, entry["response_headers"])
+}
+
+func TestNewAccessLogExtraHeader(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := Intercept(NewAccessLog(logger, "remoteClientId").Wrap(http.NotFoundHandler()))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("remoteClientId", "client-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := decodeLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "INFO", entries[0]["level"])
+	assert.Equal(t, map[string]any{"remoteclientid": "client-1"}, entries[0]["request_headers"])
+	assert.Equal(t, "client-1", entries[0]["remote_client_id"])
+}
+
+func TestNewAccessLogRemoteClientID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := Intercept(NewAccessLog(logger, "").Wrap(http.NotFoundHandler()))
+
+	// The field is logged even without header, as it always has been
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("remoteClientId", "client-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := decodeLogEntries(t, &buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "", entries[0]["remote_client_id"])
+	assert.Equal(t, "client-1", entries[1]["remote_client_id"])
+	assert.NotContains(t, entries[1], "request_headers")
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/cloudhut/common/jwt"
+	"github.com/cloudhut/common/rest"
+)
+
+// Authenticator is a middleware which verifies the request's bearer token and
+// puts the verified claims on the request context (see jwt.ClaimsFromContext).
+// Requests without valid token are rejected with 401 Unauthorized.
+type Authenticator struct {
+	// Realm is sent in the WWW-Authenticate header.
+	Realm string
+	// TokenExtractor returns the token of a request. It defaults to
+	// rest.TokenFromHeader. Use rest.TokenExtractorChain to accept tokens from
+	// multiple sources, e.g. cookies.
+	TokenExtractor rest.TokenExtractor
+	// ValidateClaims is an optional hook to reject verified tokens, e.g. because
+	// the subject has been disabled. Requests are rejected with 403 Forbidden
+	// if it returns an error.
+	ValidateClaims func(r *http.Request, claims *jwt.Claims) error
+
+	logger   *slog.Logger
+	verifier *jwt.Verifier
+}
+
+// NewAuthenticator creates a new Authenticator that verifies tokens with the
+// verifier.
+func NewAuthenticator(logger *slog.Logger, verifier *jwt.Verifier) *Authenticator {
+	return &Authenticator{
+		TokenExtractor: rest.TokenFromHeader,
+		logger:         logger,
+		verifier:       verifier,
+	}
+}
+
+// Wrap implements the middleware interface
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := a.TokenExtractor(r)
+		if token == "" {
+			a.challenge(w, "", "")
+			rest.SendRESTError(w, r, a.logger, &rest.Error{
+				Err:      errors.New("no bearer token provided"),
+				Status:   http.StatusUnauthorized,
+				Message:  "Authentication required",
+				IsSilent: true,
+			})
+			return
+		}
+
+		claims, err := a.verifier.Verify(r.Context(), token)
+		if err != nil {
+			if !isTokenError(err) {
+				// Keys could not be loaded, which is not the client's fault
+				rest.SendRESTError(w, r, a.logger, &rest.Error{
+					Err:     err,
+					Status:  http.StatusServiceUnavailable,
+					Message: "Authentication is temporarily unavailable",
+				})
+				return
+			}
+			a.logger.DebugContext(r.Context(), "rejected invalid bearer token",
+				slog.String("route", r.RequestURI),
+				slog.String("remote_address", r.RemoteAddr),
+				slog.Any("error", err))
+			a.challenge(w, "invalid_token", tokenErrorDescription(err))
+			rest.SendRESTError(w, r, a.logger, &rest.Error{
+				Err:      err,
+				Status:   http.StatusUnauthorized,
+				Message:  tokenErrorDescription(err),
+				IsSilent: true,
+			})
+			return
+		}
+
+		if a.ValidateClaims != nil {
+			if err := a.ValidateClaims(r, claims); err != nil {
+				rest.SendRESTError(w, r, a.logger, &rest.Error{
+					Err:      fmt.Errorf("claims of subject %q have been rejected: %w", claims.Subject, err),
+					Status:   http.StatusForbidden,
+					Message:  "You are not allowed to access this resource",
+					IsSilent: true,
+				})
+				return
+			}
+		}
+
+		SetAccessLogPrincipal(r.Context(), claims.Subject)
+		next.ServeHTTP(w, r.WithContext(jwt.ContextWithClaims(r.Context(), claims)))
+	})
+}
+
+// challenge sets the RFC 6750 WWW-Authenticate header. The error code is
+// omitted if the request did not contain any credentials.
+func (a *Authenticator) challenge(w http.ResponseWriter, errCode string, description string) {
+	params := []string{}
+	if a.Realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", a.Realm))
+	}
+	if errCode != "" {
+		params = append(params, fmt.Sprintf("error=%q", errCode))
+	}
+	if description != "" {
+		params = append(params, fmt.Sprintf("error_description=%q", description))
+	}
+
+	value := "Bearer"
+	if len(params) > 0 {
+		value += " " + strings.Join(params, ", ")
+	}
+	w.Header().Set("WWW-Authenticate", value)
+}
+
+// isTokenError returns true if the token has been rejected, as opposed to the
+// verification failing.
+func isTokenError(err error) bool {
+	for _, tokenErr := range []error{
+		jwt.ErrMalformed, jwt.ErrUnsupportedAlgorithm, jwt.ErrUnknownKey, jwt.ErrInvalidSignature,
+		jwt.ErrExpired, jwt.ErrNotValidYet, jwt.ErrInvalidIssuer, jwt.ErrInvalidAudience,
+	} {
+		if errors.Is(err, tokenErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenErrorDescription returns a description that is safe to send to the
+// client, without details such as the expected issuer.
+func tokenErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrExpired):
+		return "The access token expired"
+	case errors.Is(err, jwt.ErrNotValidYet):
+		return "The access token is not valid yet"
+	default:
+		return "The access token is invalid"
+	}
+}
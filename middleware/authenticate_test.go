@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudhut/common/jwt"
+)
+
+func hs256Token(secret []byte, payload string) string {
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthenticator(t *testing.T) {
+	secret := []byte("secret")
+	authn := NewAuthenticator(slog.Default(), jwt.NewVerifier(jwt.Config{Keys: jwt.StaticKeys{{Key: secret}}, AllowMissingExpiration: true}))
+	authn.Realm = "api"
+
+	var subject string
+	handler := authn.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = jwt.ClaimsFromContext(r.Context()).Subject
+	}))
+
+	tests := []struct {
+		name              string
+		authorization     string
+		expectedStatus    int
+		expectedChallenge string
+	}{
+		{"valid", "Bearer " + hs256Token(secret, `{"sub":"alice"}`), http.StatusOK, ""},
+		{"missing", "", http.StatusUnauthorized, `Bearer realm="api"`},
+		{"expired", "Bearer " + hs256Token(secret, `{"sub":"alice","exp":1}`), http.StatusUnauthorized,
+			`Bearer realm="api", error="invalid_token", error_description="The access token expired"`},
+		{"wrong secret", "Bearer " + hs256Token([]byte("other"), `{"sub":"alice"}`), http.StatusUnauthorized,
+			`Bearer realm="api", error="invalid_token", error_description="The access token is invalid"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject = ""
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedChallenge, rec.Header().Get("WWW-Authenticate"))
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "alice", subject)
+			}
+		})
+	}
+}
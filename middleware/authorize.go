@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cloudhut/common/jwt"
+	"github.com/cloudhut/common/rest"
+)
+
+// Authorizer creates middlewares which check the permissions of authenticated
+// requests, based on the claims that the Authenticator has put on the request
+// context. The middlewares compose with chi route groups:
+//
+//	router.With(authz.RequireScopes("topics:write")).Post("/topics", createTopic)
+//
+// Denied requests are counted per route pattern.
+type Authorizer struct {
+	// Scopes returns the granted scopes of the claims. It defaults to
+	// (*jwt.Claims).Scopes.
+	Scopes func(claims *jwt.Claims) []string
+	// Roles returns the roles of the claims. It defaults to (*jwt.Claims).Roles.
+	Roles func(claims *jwt.Claims) []string
+
+	logger  *slog.Logger
+	denials *prometheus.CounterVec
+}
+
+// AuthorizerOptions configures the Authorizer.
+type AuthorizerOptions struct {
+	// Registerer registers the denial counter. It defaults to
+	// prometheus.DefaultRegisterer. Authorizers with the same metrics
+	// namespace share the counter.
+	Registerer prometheus.Registerer
+}
+
+// NewAuthorizer creates a new Authorizer and registers its denial counter.
+func NewAuthorizer(logger *slog.Logger, metricsNamespace string) *Authorizer {
+	return NewAuthorizerWithOptions(logger, metricsNamespace, AuthorizerOptions{})
+}
+
+// NewAuthorizerWithOptions creates a new Authorizer and registers its denial
+// counter at the configured registerer.
+func NewAuthorizerWithOptions(logger *slog.Logger, metricsNamespace string, opts AuthorizerOptions) *Authorizer {
+	denials := registerCollector(opts.Registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "authorization_denials_total",
+		Help:      "Number of HTTP requests that have been denied due to missing permissions.",
+	}, []string{"method", "route", "reason"}))
+
+	return &Authorizer{
+		Scopes:  (*jwt.Claims).Scopes,
+		Roles:   (*jwt.Claims).Roles,
+		logger:  logger,
+		denials: denials,
+	}
+}
+
+// RequireScopes returns a middleware which only passes requests whose claims
+// grant all the given scopes.
+func (a *Authorizer) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return a.require("missing_scope", func(w http.ResponseWriter, claims *jwt.Claims) error {
+		granted := a.Scopes(claims)
+		var missing []string
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		// RFC 6750 tells the client which scopes are necessary
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=%q, scope=%q", "insufficient_scope", strings.Join(scopes, " ")))
+		return fmt.Errorf("missing scopes %q", missing)
+	})
+}
+
+// RequireAnyRole returns a middleware which only passes requests whose claims
+// contain at least one of the given roles.
+func (a *Authorizer) RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
+	return a.require("missing_role", func(_ http.ResponseWriter, claims *jwt.Claims) error {
+		for _, role := range a.Roles(claims) {
+			if slices.Contains(roles, role) {
+				return nil
+			}
+		}
+		return fmt.Errorf("none of the required roles %q", roles)
+	})
+}
+
+// require returns a middleware which denies requests if check returns an
+// error. Unauthenticated requests are rejected with 401 Unauthorized.
+func (a *Authorizer) require(reason string, check func(w http.ResponseWriter, claims *jwt.Claims) error) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := jwt.ClaimsFromContext(r.Context())
+			if claims == nil {
+				a.denials.WithLabelValues(r.Method, getRoutePattern(r), "unauthenticated").Inc()
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				rest.SendRESTError(w, r, a.logger, &rest.Error{
+					Err:      errors.New("request has not been authenticated"),
+					Status:   http.StatusUnauthorized,
+					Message:  "Authentication required",
+					IsSilent: true,
+				})
+				return
+			}
+
+			if err := check(w, claims); err != nil {
+				a.denials.WithLabelValues(r.Method, getRoutePattern(r), reason).Inc()
+				rest.SendRESTError(w, r, a.logger, &rest.Error{
+					Err:      fmt.Errorf("subject %q is not authorized: %w", claims.Subject, err),
+					Status:   http.StatusForbidden,
+					Message:  "You are not allowed to access this resource",
+					IsSilent: true,
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
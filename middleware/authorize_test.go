@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudhut/common/jwt"
+)
+
+func TestAuthorizer(t *testing.T) {
+	secret := []byte("secret")
+	authn := NewAuthenticator(slog.Default(), jwt.NewVerifier(jwt.Config{Keys: jwt.StaticKeys{{Key: secret}}, AllowMissingExpiration: true}))
+	authz := NewAuthorizer(slog.Default(), "authz_test")
+
+	router := chi.NewRouter()
+	router.Get("/public", func(w http.ResponseWriter, r *http.Request) {})
+	router.With(authz.RequireScopes("topics:read")).Get("/unauthenticated", func(w http.ResponseWriter, r *http.Request) {})
+	router.Group(func(r chi.Router) {
+		r.Use(authn.Wrap)
+		r.With(authz.RequireScopes("topics:read", "topics:write")).Post("/topics/{topicName}", func(w http.ResponseWriter, r *http.Request) {})
+		r.With(authz.RequireAnyRole("admin", "operator")).Delete("/topics/{topicName}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	serve := func(method string, target string, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if payload != "" {
+			req.Header.Set("Authorization", "Bearer "+hs256Token(secret, payload))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/topics/orders", `{"sub":"alice","scope":"topics:read topics:write"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve(http.MethodPost, "/topics/orders", `{"sub":"alice","scp":["topics:read"]}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, `Bearer error="insufficient_scope", scope="topics:read topics:write"`, rec.Header().Get("WWW-Authenticate"))
+
+	rec = serve(http.MethodDelete, "/topics/orders", `{"sub":"alice","roles":["viewer","operator"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve(http.MethodDelete, "/topics/orders", `{"sub":"alice","roles":["viewer"]}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = serve(http.MethodGet, "/unauthenticated", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(authz.denials.WithLabelValues(http.MethodPost, "/topics/{topicName}", "missing_scope")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(authz.denials.WithLabelValues(http.MethodDelete, "/topics/{topicName}", "missing_role")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(authz.denials.WithLabelValues(http.MethodGet, "/unauthenticated", "unauthenticated")))
+}
+
+func TestAuthorizerRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	opts := AuthorizerOptions{Registerer: registry}
+
+	// Authorizers of several routers share the counter instead of panicking
+	first := NewAuthorizerWithOptions(slog.Default(), "authz_registerer_test", opts)
+	second := NewAuthorizerWithOptions(slog.Default(), "authz_registerer_test", opts)
+	assert.Same(t, first.denials, second.denials)
+
+	first.denials.WithLabelValues(http.MethodGet, "/", "unauthenticated").Inc()
+	count, err := testutil.GatherAndCount(registry, "authz_registerer_test_authorization_denials_total")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.NotPanics(t, func() {
+		NewAuthorizer(slog.Default(), "authz_registerer_test")
+		NewAuthorizer(slog.Default(), "authz_registerer_test")
+	})
+}
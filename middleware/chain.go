@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// NewChain creates the Intercept, Recoverer, AccessLog and Instrument
+// middlewares in the order in which they must be applied:
+//
+//	router.Use(middleware.NewChain(logger, "kowl", middleware.AccessLogOptions{})...)
+//
+// The Recoverer is applied before the AccessLog and Instrument. They recover
+// panics of the handlers, log and observe the request with status 500 and
+// pass the panic on to the Recoverer, which responds.
+func NewChain(logger *slog.Logger, metricsNamespace string, accessLogOpts AccessLogOptions) chi.Middlewares {
+	return chi.Chain(
+		Intercept,
+		(&Recoverer{Logger: logger}).Wrap,
+		NewAccessLogWithOptions(logger, accessLogOpts).Wrap,
+		NewInstrument(metricsNamespace).Wrap,
+	)
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChainPanic(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	router := chi.NewRouter()
+	router.Use(NewChain(logger, "chain_test", AccessLogOptions{RoutePattern: true})...)
+	router.Get("/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	var accessLogs []map[string]any
+	for _, entry := range decodeLogEntries(t, &buf) {
+		if entry["log_type"] == "access" {
+			accessLogs = append(accessLogs, entry)
+		}
+	}
+	require.Len(t, accessLogs, 1)
+	assert.Equal(t, "500", accessLogs[0]["status"])
+	assert.Equal(t, "/panic", accessLogs[0]["route"])
+
+	count, err := testutil.GatherAndCount(prometheus.DefaultGatherer, "chain_test_request_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestAccessLogWithoutIntercept(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := NewAccessLog(logger, "").Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	entries := decodeLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "202", entries[0]["status"])
+	assert.Equal(t, 2.0, entries[0]["bytes_sent"])
+}
+
+func TestInstrumentRepanics(t *testing.T) {
+	instrument := NewInstrument("instrument_panic_test")
+	handler := instrument.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") }))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+	count, err := testutil.GatherAndCount(prometheus.DefaultGatherer, "instrument_panic_test_request_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,237 @@
+// Package compress provides a HTTP middleware that compresses response bodies
+// based on the encodings a client accepts.
+//
+// It lives in its own package so that the rest package can wire it into every
+// server without creating an import cycle with the middleware package.
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudhut/common/header"
+)
+
+// DefaultMinSize is the minimum size (in bytes) a response body must have
+// before it is compressed. Compressing very small bodies often results in a
+// larger payload than the uncompressed one.
+const DefaultMinSize = 1024
+
+// Encoder is a compressing writer. Encoders that implement Flush() error are
+// flushed when the handler flushes the response, encoders that implement
+// Reset(io.Writer) are pooled and reused across requests.
+type Encoder interface {
+	io.WriteCloser
+}
+
+// EncoderFunc creates a new Encoder for the given compression level. The level
+// is the configured level in the range 1-9 and may have to be mapped to the
+// level range of the underlying compression algorithm.
+type EncoderFunc func(w io.Writer, level int) (Encoder, error)
+
+// Compressor is a middleware which compresses response bodies using the best
+// encoding that is supported by both the server and the client.
+type Compressor struct {
+	level   int
+	minSize int
+
+	// encoders maps encoding names (e.g. "gzip") to their encoder constructors.
+	encoders map[string]EncoderFunc
+	// precedence lists the registered encodings in the order in which they are
+	// preferred if the client accepts multiple encodings with the same q-value.
+	precedence []string
+	pools      map[string]*sync.Pool
+
+	excludedTypes    map[string]struct{}
+	excludedPrefixes []string
+}
+
+// NewCompressor creates a new compression middleware using the given level.
+// Valid levels are 1 (fastest) to 9 (best compression). Gzip and deflate are
+// supported out of the box, further encodings such as "zstd" or "br" can be
+// added via SetEncoder.
+func NewCompressor(level int) *Compressor {
+	if level < flate.BestSpeed {
+		level = flate.BestSpeed
+	}
+	if level > flate.BestCompression {
+		level = flate.BestCompression
+	}
+
+	c := &Compressor{
+		level:    level,
+		minSize:  DefaultMinSize,
+		encoders: make(map[string]EncoderFunc),
+		pools:    make(map[string]*sync.Pool),
+		excludedTypes: map[string]struct{}{
+			"application/gzip":             {},
+			"application/x-gzip":           {},
+			"application/zip":              {},
+			"application/zstd":             {},
+			"application/x-bzip2":          {},
+			"application/x-7z-compressed":  {},
+			"application/x-rar-compressed": {},
+			"application/x-xz":             {},
+			"application/pdf":              {},
+			"application/octet-stream":     {},
+			"font/woff":                    {},
+			"font/woff2":                   {},
+		},
+		excludedPrefixes: []string{"image/", "video/", "audio/"},
+	}
+
+	c.SetEncoder("deflate", func(w io.Writer, level int) (Encoder, error) {
+		return flate.NewWriter(w, level)
+	})
+	c.SetEncoder("gzip", func(w io.Writer, level int) (Encoder, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+
+	return c
+}
+
+// SetEncoder registers (or replaces) the encoder for the given content coding.
+// Encoders registered later take precedence over earlier registered encoders
+// if the client has no preference between them.
+func (c *Compressor) SetEncoder(encoding string, fn EncoderFunc) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || fn == nil {
+		return
+	}
+
+	for i, e := range c.precedence {
+		if e == encoding {
+			c.precedence = append(c.precedence[:i], c.precedence[i+1:]...)
+			break
+		}
+	}
+	c.encoders[encoding] = fn
+	c.precedence = append([]string{encoding}, c.precedence...)
+	c.pools[encoding] = &sync.Pool{}
+}
+
+// SetMinSize sets the minimum body size in bytes which is required before a
+// response will be compressed.
+func (c *Compressor) SetMinSize(size int) {
+	c.minSize = size
+}
+
+// Wrap implements the middleware interface. Responses vary by Accept-Encoding
+// unless they have no body that could be compressed, i.e. responses to HEAD
+// requests and 304 Not Modified responses.
+func (c *Compressor) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// An empty encoding passes the response through, only adding Vary
+		encoding := c.selectEncoding(r.Header)
+		cw := &compressWriter{
+			ResponseWriter: w,
+			compressor:     c,
+			encoding:       encoding,
+			status:         http.StatusOK,
+		}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// selectEncoding returns the registered encoding with the highest q-value that
+// is accepted by the client. An empty string is returned if the response must
+// not be compressed.
+func (c *Compressor) selectEncoding(h http.Header) string {
+	specs := header.ParseAccept(h, "Accept-Encoding")
+	if len(specs) == 0 {
+		return ""
+	}
+
+	qualities := make(map[string]float64, len(specs))
+	wildcard := -1.0
+	for _, spec := range specs {
+		value := strings.ToLower(spec.Value)
+		if value == "*" {
+			wildcard = spec.Q
+			continue
+		}
+		qualities[value] = spec.Q
+	}
+
+	candidates := make([]string, 0, len(c.precedence))
+	for _, encoding := range c.precedence {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > 0 {
+			candidates = append(candidates, encoding)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	quality := func(encoding string) float64 {
+		if q, ok := qualities[encoding]; ok {
+			return q
+		}
+		return wildcard
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return quality(candidates[i]) > quality(candidates[j])
+	})
+
+	return candidates[0]
+}
+
+// isCompressible returns whether responses with the given Content-Type should
+// be compressed. Already compressed formats are skipped.
+func (c *Compressor) isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	if _, ok := c.excludedTypes[mediaType]; ok {
+		return false
+	}
+	for _, prefix := range c.excludedPrefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// getEncoder returns a pooled encoder for the given encoding or creates a new
+// one if the pool is empty.
+func (c *Compressor) getEncoder(encoding string, w io.Writer) (Encoder, error) {
+	if enc, ok := c.pools[encoding].Get().(Encoder); ok {
+		enc.(resetter).Reset(w)
+		return enc, nil
+	}
+	return c.encoders[encoding](w, c.level)
+}
+
+// putEncoder returns an encoder to its pool if it can be reused.
+func (c *Compressor) putEncoder(encoding string, enc Encoder) {
+	if _, ok := enc.(resetter); ok {
+		c.pools[encoding].Put(enc)
+	}
+}
+
+type resetter interface {
+	Reset(io.Writer)
+}
+
+type flusher interface {
+	Flush() error
+}
@@ -0,0 +1,149 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectEncoding(t *testing.T) {
+	c := NewCompressor(5)
+
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0.1, deflate;q=0.5", "deflate"},
+		{"*, gzip;q=0", "deflate"},
+		{"br", ""},
+	}
+
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.acceptEncoding != "" {
+			h.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		assert.Equal(t, tt.expected, c.selectEncoding(h), "Accept-Encoding: %q", tt.acceptEncoding)
+	}
+}
+
+func TestCompressor(t *testing.T) {
+	largeBody := strings.Repeat("hello world ", 500)
+
+	tests := []struct {
+		name             string
+		contentType      string
+		body             string
+		expectedEncoding string
+	}{
+		{
+			name:             "large json body",
+			contentType:      "application/json",
+			body:             largeBody,
+			expectedEncoding: "gzip",
+		},
+		{
+			name:             "small body",
+			contentType:      "application/json",
+			body:             "{}",
+			expectedEncoding: "",
+		},
+		{
+			name:             "already compressed content type",
+			contentType:      "image/png",
+			body:             largeBody,
+			expectedEncoding: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ww middleware.WrapResponseWriter
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				ww = middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+				ww.Header().Set("Content-Type", tt.contentType)
+				ww.WriteHeader(http.StatusCreated)
+				ww.Write([]byte(tt.body))
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			NewCompressor(5).Wrap(http.HandlerFunc(handler)).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			assert.Equal(t, tt.expectedEncoding, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, http.StatusCreated, ww.Status())
+			assert.Equal(t, len(tt.body), ww.BytesWritten())
+
+			body := rec.Body.Bytes()
+			if tt.expectedEncoding == "gzip" {
+				assert.Less(t, len(body), len(tt.body))
+				gr, err := gzip.NewReader(rec.Body)
+				require.NoError(t, err)
+				body, err = io.ReadAll(gr)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
+
+func TestCompressorVary(t *testing.T) {
+	body := strings.Repeat("hello world ", 500)
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		status         int
+		expectedVary   string
+	}{
+		{name: "compressed", method: http.MethodGet, acceptEncoding: "gzip", status: http.StatusOK, expectedVary: "Accept-Encoding"},
+		{name: "no accepted encoding", method: http.MethodGet, status: http.StatusOK, expectedVary: "Accept-Encoding"},
+		{name: "head", method: http.MethodHead, acceptEncoding: "gzip", status: http.StatusOK},
+		{name: "not modified", method: http.MethodGet, acceptEncoding: "gzip", status: http.StatusNotModified},
+		{name: "not modified without accepted encoding", method: http.MethodGet, status: http.StatusNotModified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusOK && r.Method != http.MethodHead {
+					w.Write([]byte(body))
+				}
+			}
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			NewCompressor(5).Wrap(http.HandlerFunc(handler)).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.expectedVary, rec.Header().Get("Vary"))
+			if tt.method == http.MethodGet && tt.status == http.StatusOK {
+				assert.Equal(t, tt.acceptEncoding, rec.Header().Get("Content-Encoding"))
+			}
+		})
+	}
+}
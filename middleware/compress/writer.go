@@ -0,0 +1,206 @@
+package compress
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// compressWriter buffers the beginning of the response body until it can
+// decide whether the response should be compressed. The status code passed
+// to WriteHeader is forwarded unchanged, so that response wrappers (such as
+// the chi WrapResponseWriter installed by middleware.Intercept) still report
+// the correct status. The bytes they report are the uncompressed bytes written
+// by the handler.
+type compressWriter struct {
+	http.ResponseWriter
+
+	compressor *Compressor
+	encoding   string
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	encoder     Encoder
+}
+
+// WriteHeader defers writing the status code until the first bytes of the
+// body are known.
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader || cw.decided {
+		return
+	}
+	// Informational responses are passed through immediately
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+	cw.wroteHeader = true
+	if cw.encoding == "" {
+		// Nothing to compress, there is no need to buffer the body
+		cw.decided = true
+		cw.addVary()
+		cw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+// addVary adds Accept-Encoding to the Vary header, except for 304 responses,
+// which must not vary from the 200 response they are validating.
+func (cw *compressWriter) addVary() {
+	if cw.status != http.StatusNotModified {
+		cw.Header().Add("Vary", "Accept-Encoding")
+	}
+}
+
+// Write buffers the response body until the minimum size for compressing is
+// reached, afterwards it writes to the encoder or directly to the client.
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.compressor.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide determines whether the response will be compressed, writes the
+// header and flushes the buffered bytes.
+func (cw *compressWriter) decide(sizeReached bool) error {
+	cw.decided = true
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if cw.shouldCompress(sizeReached) {
+		enc, err := cw.compressor.getEncoder(cw.encoding, cw.ResponseWriter)
+		if err != nil {
+			return err
+		}
+		cw.encoder = enc
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+	}
+
+	cw.addVary()
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+func (cw *compressWriter) shouldCompress(sizeReached bool) bool {
+	if !sizeReached {
+		return false
+	}
+	if cw.status < 200 || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if size, err := strconv.Atoi(cl); err == nil && size < cw.compressor.minSize {
+			return false
+		}
+	}
+
+	return cw.compressor.isCompressible(h.Get("Content-Type"))
+}
+
+// Flush writes all buffered data to the client. A response that is flushed
+// before reaching the minimum size will still be compressed, because it is
+// most likely a stream.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		if err := cw.decide(true); err != nil {
+			return
+		}
+	}
+
+	if f, ok := cw.encoder.(flusher); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes pending data and releases the encoder. It is called by the
+// middleware once the handler has returned.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if !cw.wroteHeader {
+			// The handler did not write anything, there is nothing to compress
+			cw.addVary()
+			return nil
+		}
+		if err := cw.decide(len(cw.buf) >= cw.compressor.minSize); err != nil {
+			return err
+		}
+	}
+
+	if cw.encoder == nil {
+		return nil
+	}
+	err := cw.encoder.Close()
+	cw.compressor.putEncoder(cw.encoding, cw.encoder)
+	cw.encoder = nil
+
+	return err
+}
+
+// Hijack implements the http.Hijacker interface.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("compress: underlying http.ResponseWriter does not implement http.Hijacker")
+}
+
+// Push implements the http.Pusher interface.
+func (cw *compressWriter) Push(target string, opts *http.PushOptions) error {
+	if ps, ok := cw.ResponseWriter.(http.Pusher); ok {
+		return ps.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying http.ResponseWriter so that it can be used
+// with http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -11,28 +15,145 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// Instrument is a middleware which creates a request duration historgram for every
-// incoming HTTP request
+// Instrument is a middleware which records the duration, in-flight requests,
+// request and response sizes, time to first byte and error responses of every
+// incoming HTTP request.
 type Instrument struct {
-	duration *prometheus.HistogramVec
+	opts InstrumentOptions
+
+	duration        *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	requestSize     *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+	timeToFirstByte *prometheus.HistogramVec
+	requestErrors   *prometheus.CounterVec
+}
+
+// DefaultDurationBuckets are histogram buckets for the response time (in
+// seconds) of a network service, including one that is responding very slowly.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25, 50, 100}
+
+// DefaultSizeBuckets are histogram buckets for request and response sizes (in
+// bytes), from 100 bytes to 1 GB.
+var DefaultSizeBuckets = prometheus.ExponentialBuckets(100, 10, 8)
+
+// InstrumentOptions configures the metrics of the Instrument.
+type InstrumentOptions struct {
+	// DurationBuckets are the buckets of the request duration and time to first
+	// byte histograms. It defaults to DefaultDurationBuckets.
+	DurationBuckets []float64
+	// SizeBuckets are the buckets of the request and response size
+	// histograms. It defaults to DefaultSizeBuckets.
+	SizeBuckets []float64
+	// NativeHistogramBucketFactor enables Prometheus native histograms if it
+	// is greater than 1, see prometheus.HistogramOpts. Classic buckets are
+	// only exposed alongside if they are configured explicitly.
+	NativeHistogramBucketFactor float64
+
+	// Labels are added to the method, route and status_code labels of all
+	// metrics except the in-flight gauge. Their values must have a low
+	// cardinality.
+	Labels []InstrumentLabel
+
+	// TraceID returns the trace ID of the request context, which is attached
+	// to the observations as exemplar. Exemplars are disabled if it is nil or
+	// returns an empty string.
+	TraceID func(ctx context.Context) string
+
+	// Registerer registers the metrics. It defaults to
+	// prometheus.DefaultRegisterer. Instruments with the same metrics namespace
+	// and options share their metrics, e.g. if multiple routers are
+	// instrumented.
+	Registerer prometheus.Registerer
 }
 
+// InstrumentLabel is an additional label whose value is determined after the
+// request has been served.
+type InstrumentLabel struct {
+	Name  string
+	Value func(r *http.Request) string
+}
+
+// HostLabel labels the metrics with the requested host name. Only use it if
+// the server is reachable via a limited number of host names.
+var HostLabel = InstrumentLabel{Name: "host", Value: serverAddress}
+
+// HandlerNameLabel labels the metrics with the handler name set by
+// HandlerName, or "other" if none has been set.
+var HandlerNameLabel = InstrumentLabel{Name: "handler", Value: func(r *http.Request) string {
+	holder, ok := r.Context().Value(handlerNameCtxKey{}).(*handlerNameHolder)
+	if !ok {
+		return "other"
+	}
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+	if holder.name == "" {
+		return "other"
+	}
+	return holder.name
+}}
+
 // NewInstrument creates a prometheus preinitialized instance which then can be used to
-// bind a middleware to a router
+// bind a middleware to a router. It uses the default InstrumentOptions, so that
+// it shares the metrics with instruments created by NewInstrumentWithOptions
+// in the same namespace.
 func NewInstrument(metricsNamespace string) *Instrument {
-	// DefBuckets are histogram buckets for the response time (in seconds)
-	// of a network service, including one that is responding very slowly.
-	buckets := []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25, 50, 100}
-	requestDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	return NewInstrumentWithOptions(metricsNamespace, InstrumentOptions{})
+}
+
+// NewInstrumentWithOptions creates an Instrument with the given options. Error
+// responses are those with a status code of 400 and above.
+func NewInstrumentWithOptions(metricsNamespace string, opts InstrumentOptions) *Instrument {
+	durationBuckets := opts.DurationBuckets
+	sizeBuckets := opts.SizeBuckets
+	if opts.NativeHistogramBucketFactor <= 1 {
+		if durationBuckets == nil {
+			durationBuckets = DefaultDurationBuckets
+		}
+		if sizeBuckets == nil {
+			sizeBuckets = DefaultSizeBuckets
+		}
+	}
+	labelNames := []string{"method", "route", "status_code"}
+	for _, label := range opts.Labels {
+		labelNames = append(labelNames, label.Name)
+	}
+
+	newHistogram := func(name string, help string, buckets []float64) *prometheus.HistogramVec {
+		histogramOpts := prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      name,
+			Help:      help,
+			Buckets:   buckets,
+		}
+		if opts.NativeHistogramBucketFactor > 1 {
+			histogramOpts.NativeHistogramBucketFactor = opts.NativeHistogramBucketFactor
+			histogramOpts.NativeHistogramMaxBucketNumber = 160
+			histogramOpts.NativeHistogramMinResetDuration = time.Hour
+		}
+		histogram := prometheus.NewHistogramVec(histogramOpts, labelNames)
+		return registerCollector(opts.Registerer, histogram)
+	}
+
+	inFlight := registerCollector(opts.Registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "requests_in_flight",
+		Help:      "Number of HTTP requests that are currently being served.",
+	}))
+	requestErrors := registerCollector(opts.Registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: metricsNamespace,
-		Name:      "request_duration_seconds",
-		Help:      "Time (in seconds) spent serving HTTP requests.",
-		Buckets:   buckets,
-	}, []string{"method", "route", "status_code"})
-	prometheus.MustRegister(requestDuration)
+		Name:      "request_errors_total",
+		Help:      "Number of HTTP requests that have been answered with a status code of 400 or above.",
+	}, labelNames))
 
 	return &Instrument{
-		duration: requestDuration,
+		opts:            opts,
+		duration:        newHistogram("request_duration_seconds", "Time (in seconds) spent serving HTTP requests.", durationBuckets),
+		inFlight:        inFlight,
+		requestSize:     newHistogram("request_size_bytes", "Size (in bytes) of the HTTP request bodies according to the Content-Length header, or as read by the handler if it is unknown.", sizeBuckets),
+		responseSize:    newHistogram("response_size_bytes", "Size (in bytes) of the HTTP response bodies.", sizeBuckets),
+		timeToFirstByte: newHistogram("time_to_first_byte_seconds", "Time (in seconds) until the first byte of the HTTP response body has been written. Responses without body are not observed.", durationBuckets),
+		requestErrors:   requestErrors,
 	}
 }
 
@@ -40,19 +161,141 @@ func NewInstrument(metricsNamespace string) *Instrument {
 func (i *Instrument) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
+		ww := wrapResponseWriter(w, r)
 
-		ww, ok := w.(middleware.WrapResponseWriter)
-		if !ok {
-			panic("could not convert to middleware.WrapResponseWriter")
+		i.inFlight.Inc()
+		defer i.inFlight.Dec()
+
+		r = r.WithContext(context.WithValue(r.Context(), handlerNameCtxKey{}, &handlerNameHolder{}))
+		var m requestMeasurement
+		// The size of bodies without Content-Length is counted while they are
+		// read
+		if r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody {
+			m.body = &countingReader{ReadCloser: r.Body}
+			r.Body = m.body
 		}
-		duration := time.Since(start)
-		status := strconv.Itoa(ww.Status())
-		route := i.getRoutePattern(r)
-		i.duration.WithLabelValues(r.Method, route, status).Observe(duration.Seconds())
+		// The first byte of the response body is recorded by a writer of its
+		// own, which passes the writes on to ww. This keeps a writer that
+		// another middleware has tee'd to ww.
+		m.firstByte = &firstByteRecorder{}
+		tw := middleware.NewWrapResponseWriter(ww, r.ProtoMajor)
+		tw.Tee(m.firstByte)
+
+		// Re-panics after recording status 500, see NewChain
+		defer func() {
+			if err := recover(); err != nil {
+				i.observe(r, ww, http.StatusInternalServerError, start, &m)
+				panic(err)
+			}
+		}()
+
+		next.ServeHTTP(tw, r)
+
+		i.observe(r, ww, ww.Status(), start, &m)
 	})
 }
 
+// requestMeasurement holds the measurements taken while the request is served.
+type requestMeasurement struct {
+	body      *countingReader
+	firstByte *firstByteRecorder
+}
+
+// observe records the metrics of the request.
+func (i *Instrument) observe(r *http.Request, ww middleware.WrapResponseWriter, status int, start time.Time, m *requestMeasurement) {
+	duration := time.Since(start)
+	labelValues := []string{r.Method, getRoutePattern(r), strconv.Itoa(status)}
+	for _, label := range i.opts.Labels {
+		labelValues = append(labelValues, label.Value(r))
+	}
+	var exemplar prometheus.Labels
+	if i.opts.TraceID != nil {
+		if traceID := i.opts.TraceID(r.Context()); traceID != "" {
+			exemplar = prometheus.Labels{"trace_id": traceID}
+		}
+	}
+
+	requestSize := max(r.ContentLength, 0)
+	if m.body != nil {
+		requestSize = m.body.n.Load()
+	}
+
+	observeWithExemplar(i.duration.WithLabelValues(labelValues...), duration.Seconds(), exemplar)
+	// Responses without body have no first byte
+	if firstByteAt := m.firstByte.at.Load(); firstByteAt != nil {
+		observeWithExemplar(i.timeToFirstByte.WithLabelValues(labelValues...), firstByteAt.Sub(start).Seconds(), exemplar)
+	}
+	observeWithExemplar(i.requestSize.WithLabelValues(labelValues...), float64(requestSize), exemplar)
+	observeWithExemplar(i.responseSize.WithLabelValues(labelValues...), float64(ww.BytesWritten()), exemplar)
+	if status >= http.StatusBadRequest {
+		counter := i.requestErrors.WithLabelValues(labelValues...)
+		if adder, ok := counter.(prometheus.ExemplarAdder); ok && exemplar != nil {
+			adder.AddWithExemplar(1, exemplar)
+		} else {
+			counter.Inc()
+		}
+	}
+}
+
+// observeWithExemplar observes the value and attaches the exemplar, if any.
+func observeWithExemplar(observer prometheus.Observer, value float64, exemplar prometheus.Labels) {
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(value, exemplar)
+		return
+	}
+	observer.Observe(value)
+}
+
+// countingReader counts the bytes read from a request body of unknown length.
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// firstByteRecorder records the time the first byte of the response body has
+// been written. It is tee'd to the response writer.
+type firstByteRecorder struct {
+	at atomic.Pointer[time.Time]
+}
+
+func (f *firstByteRecorder) Write(p []byte) (int, error) {
+	if len(p) > 0 && f.at.Load() == nil {
+		now := time.Now()
+		f.at.CompareAndSwap(nil, &now)
+	}
+	return len(p), nil
+}
+
+type handlerNameCtxKey struct{}
+
+type handlerNameHolder struct {
+	mu   sync.Mutex
+	name string
+}
+
+// HandlerName returns a middleware which names the handler for the
+// HandlerNameLabel of the Instrument:
+//
+//	router.With(middleware.HandlerName("listTopics")).Get("/topics", listTopics)
+func HandlerName(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if holder, ok := r.Context().Value(handlerNameCtxKey{}).(*handlerNameHolder); ok {
+				holder.mu.Lock()
+				holder.name = name
+				holder.mu.Unlock()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // getRoutePattern returns the route pattern of the requested URL, so that we can use
 // them as prometheus label without ending up with thousands of different metric serieses
 // due to tons of different labels. Get route patterns like this:
@@ -62,8 +305,11 @@ func (i *Instrument) Wrap(next http.Handler) http.Handler {
 //	route pattern: "users_user_id_billing_history"
 //
 // c) The requests did not match any route handlers, return "other"
-func (i *Instrument) getRoutePattern(r *http.Request) string {
+func getRoutePattern(r *http.Request) string {
 	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return "other"
+	}
 	if pattern := rctx.RoutePattern(); pattern != "" {
 		return pattern
 	}
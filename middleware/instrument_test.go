@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type traceIDCtxKey struct{}
+
+// gatherMetric returns the metric family with the given name from the default
+// registry.
+func gatherMetric(t *testing.T, name string) *dto.MetricFamily {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == name {
+			return family
+		}
+	}
+	require.Failf(t, "metric not found", "metric %q has not been registered", name)
+	return nil
+}
+
+func TestInstrumentWithOptions(t *testing.T) {
+	instrument := NewInstrumentWithOptions("instrument_test", InstrumentOptions{
+		Labels: []InstrumentLabel{HostLabel, HandlerNameLabel},
+		TraceID: func(ctx context.Context) string {
+			traceID, _ := ctx.Value(traceIDCtxKey{}).(string)
+			return traceID
+		},
+	})
+
+	router := chi.NewRouter()
+	router.Use(instrument.Wrap)
+	router.With(HandlerName("createTopic")).Post("/topics/{topicName}", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		assert.Equal(t, 1.0, testutil.ToFloat64(instrument.inFlight))
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("topic exists"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/topics/orders", strings.NewReader(`{"partitions":3}`))
+	req = req.WithContext(context.WithValue(req.Context(), traceIDCtxKey{}, "4bf92f3577b34da6a3ce929d0e0e4736"))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(instrument.inFlight))
+	assert.Equal(t, 1.0, testutil.ToFloat64(instrument.requestErrors.WithLabelValues(http.MethodPost, "/topics/{topicName}", "409", "example.com", "createTopic")))
+
+	for name, sum := range map[string]float64{
+		"instrument_test_request_size_bytes":  16,
+		"instrument_test_response_size_bytes": 12,
+	} {
+		metrics := gatherMetric(t, name).GetMetric()
+		require.Len(t, metrics, 1, name)
+		assert.Equal(t, uint64(1), metrics[0].GetHistogram().GetSampleCount(), name)
+		assert.Equal(t, sum, metrics[0].GetHistogram().GetSampleSum(), name)
+	}
+
+	ttfb := gatherMetric(t, "instrument_test_time_to_first_byte_seconds").GetMetric()[0].GetHistogram()
+	duration := gatherMetric(t, "instrument_test_request_duration_seconds").GetMetric()[0].GetHistogram()
+	assert.LessOrEqual(t, ttfb.GetSampleSum(), duration.GetSampleSum())
+
+	var exemplarTraceIDs []string
+	for _, bucket := range duration.GetBucket() {
+		if exemplar := bucket.GetExemplar(); exemplar != nil {
+			for _, label := range exemplar.GetLabel() {
+				exemplarTraceIDs = append(exemplarTraceIDs, label.GetName()+"="+label.GetValue())
+			}
+		}
+	}
+	assert.Equal(t, []string{"trace_id=4bf92f3577b34da6a3ce929d0e0e4736"}, exemplarTraceIDs)
+}
+
+func TestInstrumentMeasurements(t *testing.T) {
+	instrument := NewInstrumentWithOptions("instrument_measurement_test", InstrumentOptions{})
+
+	// A writer tee'd by a preceding middleware must keep receiving the body
+	var teed bytes.Buffer
+	router := chi.NewRouter()
+	router.Use(Intercept, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapResponseWriter(w, r).Tee(&teed)
+			next.ServeHTTP(w, r)
+		})
+	}, instrument.Wrap)
+	router.Delete("/topics", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	router.Post("/ignored", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ignored")) })
+	router.Post("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("read"))
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/topics", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ignored", strings.NewReader("not read by the handler")))
+	req := httptest.NewRequest(http.MethodPost, "/chunked", strings.NewReader("chunked"))
+	req.ContentLength = -1
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "ignoredread", teed.String())
+
+	histogram := func(name string, route string) *dto.Histogram {
+		for _, metric := range gatherMetric(t, name).GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "route" && label.GetValue() == route {
+					return metric.GetHistogram()
+				}
+			}
+		}
+		return nil
+	}
+
+	// Responses without body have no time to first byte
+	assert.Nil(t, histogram("instrument_measurement_test_time_to_first_byte_seconds", "/topics"))
+	assert.NotNil(t, histogram("instrument_measurement_test_request_duration_seconds", "/topics"))
+	assert.NotNil(t, histogram("instrument_measurement_test_time_to_first_byte_seconds", "/ignored"))
+
+	assert.Equal(t, 23.0, histogram("instrument_measurement_test_request_size_bytes", "/ignored").GetSampleSum())
+	assert.Equal(t, 7.0, histogram("instrument_measurement_test_request_size_bytes", "/chunked").GetSampleSum())
+}
+
+func TestInstrumentNativeHistograms(t *testing.T) {
+	instrument := NewInstrumentWithOptions("instrument_native_test", InstrumentOptions{NativeHistogramBucketFactor: 1.1})
+	handler := instrument.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	histogram := gatherMetric(t, "instrument_native_test_request_duration_seconds").GetMetric()[0].GetHistogram()
+	assert.Empty(t, histogram.GetBucket())
+	assert.NotZero(t, histogram.GetSchema())
+	assert.Equal(t, uint64(1), histogram.GetSampleCount())
+}
+
+func TestInstrumentRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	opts := InstrumentOptions{Registerer: registry}
+
+	// Instruments of several routers share the metrics instead of panicking
+	first := NewInstrumentWithOptions("instrument_registerer_test", opts)
+	second := NewInstrumentWithOptions("instrument_registerer_test", opts)
+	handler := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }
+	first.Wrap(http.HandlerFunc(handler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	second.Wrap(http.HandlerFunc(handler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, 2.0, testutil.ToFloat64(first.requestErrors.WithLabelValues(http.MethodGet, "other", "404")))
+
+	count, err := testutil.GatherAndCount(registry, "instrument_registerer_test_request_duration_seconds", "instrument_registerer_test_requests_in_flight")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	assert.NotPanics(t, func() {
+		NewChain(slog.Default(), "instrument_registerer_test", AccessLogOptions{})
+		NewChain(slog.Default(), "instrument_registerer_test", AccessLogOptions{})
+	})
+}
+
+func TestInstrumentConstructorsShareMetrics(t *testing.T) {
+	var legacy, instrument *Instrument
+	require.NotPanics(t, func() {
+		legacy = NewInstrument("instrument_shared_test")
+		instrument = NewInstrumentWithOptions("instrument_shared_test", InstrumentOptions{})
+	})
+	assert.Same(t, legacy.duration, instrument.duration)
+
+	legacy.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	histogram := gatherMetric(t, "instrument_shared_test_request_duration_seconds").GetMetric()[0].GetHistogram()
+	assert.NotEmpty(t, histogram.GetBucket())
+	assert.Zero(t, histogram.GetSchema(), "native histograms must be disabled by default")
+}
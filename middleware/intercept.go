@@ -14,3 +14,12 @@ func Intercept(next http.Handler) http.Handler {
 		next.ServeHTTP(ww, r)
 	})
 }
+
+// wrapResponseWriter returns w if Intercept has already wrapped it and wraps
+// it otherwise, so that middlewares can read the status and written bytes.
+func wrapResponseWriter(w http.ResponseWriter, r *http.Request) middleware.WrapResponseWriter {
+	if ww, ok := w.(middleware.WrapResponseWriter); ok {
+		return ww
+	}
+	return middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+}
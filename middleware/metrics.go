@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// registerCollector registers the collector at the registerer, which defaults
+// to prometheus.DefaultRegisterer. If an equal collector has already been
+// registered, e.g. by a middleware of another router with the same metrics
+// namespace, the existing collector is returned and shared.
+func registerCollector[C prometheus.Collector](registerer prometheus.Registerer, collector C) C {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	if err := registerer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			if existing, ok := alreadyRegistered.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return collector
+}
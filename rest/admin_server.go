@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newAdminServer creates the internal admin server, which serves the Prometheus
+// metrics of the gatherer (see Server.MetricsGatherer), pprof profiles, build
+// information and the health probes.
+// It shares the main server's health registry, so that its readiness probe
+// fails as soon as the main server begins to shut down.
+func newAdminServer(cfg *Config, logger *slog.Logger, health *HealthRegistry, gatherer prometheus.Gatherer) *Server {
+	copiedCfg := *cfg
+	copiedCfg.TLS.Enabled = false
+	copiedCfg.HTTPListenAddress = cfg.AdminListenAddress
+	if copiedCfg.HTTPListenAddress == "" {
+		// The endpoints are not authenticated
+		copiedCfg.HTTPListenAddress = "127.0.0.1"
+	}
+	copiedCfg.HTTPListenPort = cfg.AdminListenPort
+	copiedCfg.AdminListenPort = 0
+	copiedCfg.HTTPListenUnixSocket = ""
+	copiedCfg.SystemdSocketActivation = false
+	copiedCfg.GracefulRestart = false
+
+	mux := http.NewServeMux()
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/buildinfo", handleBuildInfo)
+
+	healthPathPrefix := cfg.HealthPathPrefix
+	if healthPathPrefix == "" {
+		healthPathPrefix = "/health"
+	}
+
+	return &Server{
+		name: "admin",
+		cfg:  &copiedCfg,
+		Server: &http.Server{
+			ReadTimeout:       cfg.HTTPServerReadTimeout,
+			ReadHeaderTimeout: cfg.HTTPServerReadHeaderTimeout,
+			MaxHeaderBytes:    cfg.HTTPServerMaxHeaderBytes,
+			// CPU profiles and traces take longer than regular requests
+			WriteTimeout: 0,
+			IdleTimeout:  cfg.HTTPServerIdleTimeout,
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			Handler:      newHealthHandler(healthPathPrefix, health, mux),
+		},
+		Logger: logger,
+		Health: health,
+	}
+}
+
+// buildInfo is the build information of the running binary.
+type buildInfo struct {
+	GoVersion    string `json:"goVersion"`
+	Path         string `json:"path"`
+	Version      string `json:"version"`
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revisionTime,omitempty"`
+	Modified     bool   `json:"modified"`
+}
+
+func handleBuildInfo(w http.ResponseWriter, _ *http.Request) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build information is not available", http.StatusNotFound)
+		return
+	}
+
+	bi := buildInfo{
+		GoVersion: info.GoVersion,
+		Path:      info.Main.Path,
+		Version:   info.Main.Version,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			bi.Revision = setting.Value
+		case "vcs.time":
+			bi.RevisionTime = setting.Value
+		case "vcs.modified":
+			bi.Modified = setting.Value == "true"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bi)
+}
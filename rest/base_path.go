@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type basePathCtxKey struct{}
+
+// BasePathFromContext returns the base path under which the current request
+// has been served. The returned path always starts and ends with a slash
+// (e.g. "/kowl/"). If no base path is configured an empty string is returned.
+//
+// Handlers and HTML templates can use it to build absolute links that still
+// work when the application is hosted behind a path-prefixing proxy.
+func BasePathFromContext(ctx context.Context) string {
+	basePath, _ := ctx.Value(basePathCtxKey{}).(string)
+	return basePath
+}
+
+// ContextWithBasePath returns a copy of ctx that carries the given base path.
+// The base path is normalized so that it starts and ends with a slash.
+func ContextWithBasePath(ctx context.Context, basePath string) context.Context {
+	return context.WithValue(ctx, basePathCtxKey{}, normalizeBasePath(basePath))
+}
+
+// normalizeBasePath cleans the given base path and ensures that it starts and
+// ends with a slash. An empty or root base path returns an empty string.
+func normalizeBasePath(basePath string) string {
+	basePath = strings.TrimSpace(basePath)
+	if basePath == "" {
+		return ""
+	}
+
+	cleaned := path.Clean("/" + basePath)
+	if cleaned == "/" {
+		return ""
+	}
+	return cleaned + "/"
+}
+
+// newBasePathHandler wraps the given handler so that the base path is put on
+// the request context and (if configured) stripped from the request URL before
+// the request is routed.
+func newBasePathHandler(cfg *Config, next http.Handler) http.Handler {
+	defaultBasePath := normalizeBasePath(cfg.BasePath)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		basePath := defaultBasePath
+		if cfg.SetBasePathFromXForwardedPrefix {
+			if prefix := r.Header.Get("X-Forwarded-Prefix"); prefix != "" {
+				basePath = normalizeBasePath(prefix)
+			}
+		}
+
+		if basePath == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = r.WithContext(ContextWithBasePath(r.Context(), basePath))
+		if cfg.StripPrefix {
+			r = stripBasePath(r, basePath)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// stripBasePath removes the base path from the request URL. Requests whose
+// path does not start with the base path (e.g. because a proxy already removed
+// the prefix) are returned unmodified.
+func stripBasePath(r *http.Request, basePath string) *http.Request {
+	trimmed := strings.TrimSuffix(basePath, "/")
+	if r.URL.Path != trimmed && !strings.HasPrefix(r.URL.Path, basePath) {
+		return r
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, trimmed), "/")
+	r2.URL.RawPath = ""
+	if strings.HasPrefix(r.URL.RawPath, trimmed) {
+		r2.URL.RawPath = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.RawPath, trimmed), "/")
+	}
+
+	return r2
+}
@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"/", ""},
+		{"kowl", "/kowl/"},
+		{"kowl/", "/kowl/"},
+		{"/kowl", "/kowl/"},
+		{"/a//b/../c/", "/a/c/"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, normalizeBasePath(tt.input), "input %q", tt.input)
+	}
+}
+
+func TestBasePathHandler(t *testing.T) {
+	newHandler := func(cfg *Config) http.Handler {
+		router := chi.NewRouter()
+		router.Get("/api/topics", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(BasePathFromContext(r.Context())))
+		})
+		return newBasePathHandler(cfg, router)
+	}
+
+	tests := []struct {
+		name            string
+		cfg             Config
+		path            string
+		forwardedPrefix string
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{
+			name:           "no base path",
+			cfg:            Config{StripPrefix: true},
+			path:           "/api/topics",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name:           "configured base path is stripped",
+			cfg:            Config{BasePath: "kowl", StripPrefix: true},
+			path:           "/kowl/api/topics",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/kowl/",
+		},
+		{
+			name:           "already stripped by proxy",
+			cfg:            Config{BasePath: "kowl", StripPrefix: true},
+			path:           "/api/topics",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/kowl/",
+		},
+		{
+			name:           "prefix not stripped",
+			cfg:            Config{BasePath: "kowl", StripPrefix: false},
+			path:           "/kowl/api/topics",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:            "x-forwarded-prefix takes precedence",
+			cfg:             Config{BasePath: "kowl", SetBasePathFromXForwardedPrefix: true, StripPrefix: true},
+			path:            "/console/api/topics",
+			forwardedPrefix: "/console",
+			expectedStatus:  http.StatusOK,
+			expectedBody:    "/console/",
+		},
+		{
+			name:            "x-forwarded-prefix ignored when disabled",
+			cfg:             Config{BasePath: "kowl", StripPrefix: true},
+			path:            "/kowl/api/topics",
+			forwardedPrefix: "/console",
+			expectedStatus:  http.StatusOK,
+			expectedBody:    "/kowl/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.forwardedPrefix != "" {
+				req.Header.Set("X-Forwarded-Prefix", tt.forwardedPrefix)
+			}
+			rec := httptest.NewRecorder()
+
+			newHandler(&tt.cfg).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
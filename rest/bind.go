@@ -0,0 +1,192 @@
+package rest
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bindError is returned if a value could not be converted into the type of
+// the struct field it is bound to.
+type bindError struct {
+	Key  string
+	Type reflect.Type
+	Err  error
+}
+
+func (e *bindError) Error() string {
+	return fmt.Sprintf("invalid value for %q: %v", e.Key, e.Err)
+}
+
+func (e *bindError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// walkFields calls fn for every exported field of the struct that dst points
+// to. The field name is taken from the struct tag with the given key and
+// defaults to the Go field name unless onlyTagged is set, in which case fields
+// without the tag are skipped. Fields tagged with "-" are skipped, embedded
+// structs without a tag are walked recursively.
+func walkFields(dst interface{}, tagKey string, onlyTagged bool, fn func(name string, opts string, field reflect.Value) error) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("destination must be a non-nil pointer to a struct, got %T", dst)
+	}
+	return walkStruct(rv.Elem(), tagKey, onlyTagged, fn)
+}
+
+func walkStruct(v reflect.Value, tagKey string, onlyTagged bool, fn func(name string, opts string, field reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+
+		tag, hasTag := sf.Tag.Lookup(tagKey)
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		if sf.Anonymous && !hasTag {
+			if sf.Type.Kind() == reflect.Struct {
+				if err := walkStruct(fv, tagKey, onlyTagged, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			if sf.Type.Kind() == reflect.Pointer && sf.Type.Elem().Kind() == reflect.Struct && sf.IsExported() {
+				if fv.IsNil() {
+					fv.Set(reflect.New(sf.Type.Elem()))
+				}
+				if err := walkStruct(fv.Elem(), tagKey, onlyTagged, fn); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !sf.IsExported() || (onlyTagged && !hasTag) {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if err := fn(name, opts, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindValues sets the fields of the struct that dst points to from the given
+// values. It returns the keys that have been bound to a field.
+func bindValues(dst interface{}, tagKey string, values map[string][]string) (map[string]struct{}, error) {
+	bound := make(map[string]struct{})
+	err := walkFields(dst, tagKey, false, func(name string, _ string, field reflect.Value) error {
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			return nil
+		}
+		bound[name] = struct{}{}
+		if err := setFieldValue(field, vals); err != nil {
+			return &bindError{Key: name, Type: field.Type(), Err: err}
+		}
+		return nil
+	})
+	return bound, err
+}
+
+// setFieldValue parses the values into the field. Slices receive all values,
+// other types receive the first value.
+func setFieldValue(field reflect.Value, vals []string) error {
+	t := field.Type()
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(t, len(vals), len(vals))
+		for i, val := range vals {
+			if err := setScalarValue(slice.Index(i), val); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setScalarValue(field, vals[0])
+}
+
+// setScalarValue parses a single string into the given field.
+func setScalarValue(field reflect.Value, val string) error {
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setScalarValue(ptr.Elem(), val); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if field.CanAddr() && reflect.PointerTo(field.Type()).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Bool:
+		if val == "on" {
+			// HTML checkboxes are submitted with the value "on"
+			field.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %v", field.Type())
+		}
+		field.SetBytes([]byte(val))
+	default:
+		return fmt.Errorf("unsupported type %v", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Codec decodes request bodies of the media types it has been registered for
+// into dst. The request body is already limited to the decoder's MaxBodySize.
+//
+// Errors are converted into a REST error by the Decoder. Codecs can return a
+// REST error wrapped by WrapError to control the response sent to the client.
+type Codec interface {
+	Decode(r *http.Request, d *Decoder, dst interface{}) error
+}
+
+// CodecFunc is an adapter to allow the use of ordinary functions as Codec.
+type CodecFunc func(r *http.Request, d *Decoder, dst interface{}) error
+
+// Decode implements the Codec interface.
+func (f CodecFunc) Decode(r *http.Request, d *Decoder, dst interface{}) error {
+	return f(r, d, dst)
+}
+
+// RegisterCodec registers (or replaces) the codec for the given media type.
+// The media type may contain wildcards as described for Decoder.MediaTypes,
+// exact matches take precedence over wildcard matches.
+//
+// Registering a codec does not add its media type to the accepted MediaTypes.
+func (d *Decoder) RegisterCodec(mediaType string, codec Codec) {
+	if d.codecs == nil {
+		d.codecs = make(map[string]Codec)
+	}
+	d.codecs[strings.ToLower(mediaType)] = codec
+}
+
+// codecFor returns the registered codec for the given media type or nil if
+// there is none.
+func (d *Decoder) codecFor(mediaType string) Codec {
+	mediaType = strings.ToLower(mediaType)
+	if codec, ok := d.codecs[mediaType]; ok {
+		return codec
+	}
+
+	// Try the most specific wildcard patterns first
+	patterns := make([]string, 0, len(d.codecs))
+	for pattern := range d.codecs {
+		if strings.Contains(pattern, "*") && matchMediaType(pattern, mediaType) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if strings.Count(patterns[i], "*") != strings.Count(patterns[j], "*") {
+			return strings.Count(patterns[i], "*") < strings.Count(patterns[j], "*")
+		}
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	return d.codecs[patterns[0]]
+}
+
+// registerDefaultCodecs registers the built-in codecs for JSON, YAML,
+// url-encoded forms and multipart forms.
+func (d *Decoder) registerDefaultCodecs() {
+	d.RegisterCodec("application/json", CodecFunc(decodeJSON))
+	d.RegisterCodec("application/*+json", CodecFunc(decodeJSON))
+
+	d.RegisterCodec("application/yaml", CodecFunc(decodeYAML))
+	d.RegisterCodec("application/x-yaml", CodecFunc(decodeYAML))
+	d.RegisterCodec("text/yaml", CodecFunc(decodeYAML))
+	d.RegisterCodec("application/*+yaml", CodecFunc(decodeYAML))
+
+	d.RegisterCodec("application/x-www-form-urlencoded", CodecFunc(decodeForm))
+	d.RegisterCodec("multipart/form-data", CodecFunc(decodeMultipart))
+}
+
+// decodeJSON decodes a single JSON object from the request body.
+func decodeJSON(r *http.Request, d *Decoder, dst interface{}) error {
+	return d.decodeJSON(r.Body, dst)
+}
+
+func (d *Decoder) decodeJSON(body io.Reader, dst interface{}) error {
+	dec := json.NewDecoder(body)
+	if !d.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if d.UseNumber {
+		dec.UseNumber()
+	}
+
+	if err := dec.Decode(&dst); err != nil {
+		return err
+	}
+
+	err := dec.Decode(&struct{}{})
+	if err != io.EOF {
+		msg := "Request body must only contain a single JSON object"
+		return WrapError(&Error{Err: err, Status: http.StatusBadRequest, Message: msg})
+	}
+
+	return nil
+}
+
+// decodeYAML decodes a single YAML document from the request body. The
+// document is converted to JSON first, so that the destination's json struct
+// tags and the decoder's JSON settings apply.
+func decodeYAML(r *http.Request, d *Decoder, dst interface{}) error {
+	dec := yaml.NewDecoder(r.Body)
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return err
+		}
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return err
+		}
+		msg := fmt.Sprintf("request body contains badly-formed YAML: %v", err)
+		return WrapError(&Error{Err: err, Status: http.StatusBadRequest, Message: msg})
+	}
+
+	var extraDoc interface{}
+	if err := dec.Decode(&extraDoc); err != io.EOF {
+		msg := "Request body must only contain a single YAML document"
+		return WrapError(&Error{Err: err, Status: http.StatusBadRequest, Message: msg})
+	}
+
+	doc, err := yamlToJSONValue(doc)
+	if err != nil {
+		msg := fmt.Sprintf("request body cannot be represented as JSON: %v", err)
+		return WrapError(&Error{Err: err, Status: http.StatusBadRequest, Message: msg})
+	}
+	jsonBytes, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return d.decodeJSON(bytes.NewReader(jsonBytes), dst)
+}
+
+// yamlToJSONValue converts maps with non-string keys, which YAML allows but
+// JSON does not, into maps with string keys.
+func yamlToJSONValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = converted
+		}
+		return val, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			switch key := k.(type) {
+			case string:
+				m[key] = converted
+			case int, int64, uint64, float64, bool:
+				m[fmt.Sprintf("%v", key)] = converted
+			default:
+				return nil, fmt.Errorf("unsupported map key of type %T", k)
+			}
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range val {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = converted
+		}
+		return val, nil
+	default:
+		return v, nil
+	}
+}
+
+// decodeForm decodes an url-encoded form into the fields of a struct that are
+// tagged with `form:"name"`.
+func decodeForm(r *http.Request, d *Decoder, dst interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return io.EOF
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		msg := fmt.Sprintf("request body contains a badly-formed form: %v", err)
+		return WrapError(&Error{Err: err, Status: http.StatusBadRequest, Message: msg})
+	}
+
+	return d.bindForm(values, dst)
+}
+
+// bindForm binds form values to dst and rejects unknown fields unless they
+// are allowed.
+func (d *Decoder) bindForm(values map[string][]string, dst interface{}) error {
+	bound, err := bindValues(dst, "form", values)
+	if err != nil {
+		return err
+	}
+
+	if d.AllowUnknownFields {
+		return nil
+	}
+	return checkUnknownFields(values, bound)
+}
+
+// checkUnknownFields returns an error if any key of values has not been bound.
+func checkUnknownFields[T any](values map[string]T, bound map[string]struct{}) error {
+	unknown := make([]string, 0)
+	for key := range values {
+		if _, ok := bound[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Strings(unknown)
+	err := fmt.Errorf("form: unknown field %q", unknown[0])
+	msg := fmt.Sprintf("Request body contains unknown field %q", unknown[0])
+	return WrapError(&Error{Err: err, Status: http.StatusBadRequest, Message: msg})
+}
@@ -0,0 +1,277 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type topicConfig struct {
+	Name       string        `json:"name" form:"name"`
+	Partitions int           `json:"partitions" form:"partitions"`
+	Compacted  bool          `json:"compacted" form:"compacted"`
+	Retention  time.Duration `json:"-" form:"retention"`
+	Labels     []string      `json:"labels" form:"label"`
+}
+
+func newAllMediaTypesDecoder() *Decoder {
+	d := NewDecoder()
+	d.MediaTypes = []string{"application/json", "application/*+json", "application/yaml", "application/x-www-form-urlencoded", "multipart/form-data"}
+	return d
+}
+
+func TestCodecs(t *testing.T) {
+	expected := topicConfig{Name: "orders", Partitions: 3, Compacted: true, Labels: []string{"a", "b"}}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    topicConfig
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"name":"orders","partitions":3,"compacted":true,"labels":["a","b"]}`,
+			expected:    expected,
+		},
+		{
+			name:        "json with suffix",
+			contentType: "application/vnd.topic+json",
+			body:        `{"name":"orders","partitions":3,"compacted":true,"labels":["a","b"]}`,
+			expected:    expected,
+		},
+		{
+			name:        "yaml",
+			contentType: "application/yaml",
+			body:        "name: orders\npartitions: 3\ncompacted: true\nlabels:\n  - a\n  - b\n",
+			expected:    expected,
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=orders&partitions=3&compacted=on&label=a&label=b&retention=1h",
+			expected:    topicConfig{Name: "orders", Partitions: 3, Compacted: true, Labels: []string{"a", "b"}, Retention: time.Hour},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			var dst topicConfig
+			restErr := newAllMediaTypesDecoder().Decode(httptest.NewRecorder(), req, &dst)
+			require.Nil(t, restErr)
+			assert.Equal(t, tt.expected, dst)
+		})
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	tests := []struct {
+		name               string
+		contentType        string
+		body               string
+		expectedStatus     int
+		expectedViolations []FieldViolation
+	}{
+		{
+			name:           "yaml with unknown field",
+			contentType:    "application/yaml",
+			body:           "name: orders\nreplicas: 3\n",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "yaml with multiple documents",
+			contentType:    "application/yaml",
+			body:           "name: orders\n---\nname: payments\n",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "yaml with wrong type",
+			contentType:    "application/yaml",
+			body:           "partitions: three\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []FieldViolation{
+				{Field: "/partitions", Rule: "type", Message: "must be of type int"},
+			},
+		},
+		{
+			name:           "form with wrong type",
+			contentType:    "application/x-www-form-urlencoded",
+			body:           "partitions=three",
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []FieldViolation{
+				{Field: "/partitions", Rule: "type", Message: "must be of type int"},
+			},
+		},
+		{
+			name:           "form with unknown field",
+			contentType:    "application/x-www-form-urlencoded",
+			body:           "name=orders&replicas=3",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "empty form",
+			contentType:    "application/x-www-form-urlencoded",
+			body:           "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "no codec registered",
+			contentType:    "application/msgpack",
+			body:           "",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			d := newAllMediaTypesDecoder()
+			d.MediaTypes = append(d.MediaTypes, "application/msgpack")
+
+			var dst topicConfig
+			restErr := d.Decode(httptest.NewRecorder(), req, &dst)
+			require.NotNil(t, restErr)
+			assert.Equal(t, tt.expectedStatus, restErr.Status)
+			assert.Equal(t, tt.expectedViolations, restErr.Violations)
+		})
+	}
+}
+
+func TestCustomCodecError(t *testing.T) {
+	var codecErr *Error
+	d := NewDecoder()
+	d.MediaTypes = []string{"application/msgpack"}
+	d.RegisterCodec("application/msgpack", CodecFunc(func(r *http.Request, d *Decoder, dst interface{}) error {
+		// A nil REST error must not turn into a non-nil error
+		return WrapError(codecErr)
+	}))
+
+	decode := func() *Error {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+		req.Header.Set("Content-Type", "application/msgpack")
+		return d.Decode(httptest.NewRecorder(), req, &topicConfig{})
+	}
+	assert.Nil(t, decode())
+
+	cause := errors.New("unsupported msgpack extension")
+	codecErr = &Error{Err: cause, Status: http.StatusUnprocessableEntity, Message: "Unsupported payload"}
+	restErr := decode()
+	require.NotNil(t, restErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, restErr.Status)
+	assert.Equal(t, "Unsupported payload", restErr.Message)
+	assert.ErrorIs(t, WrapError(restErr), cause)
+}
+
+func TestMultipartCodec(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("name", "orders"))
+	fw, err := mw.CreateFormFile("attachment", "orders.csv")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("id,amount\n1,42\n"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/", &body).WithContext(ctx)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	d := newAllMediaTypesDecoder()
+	d.TempDir = t.TempDir()
+
+	var dst struct {
+		Name       string    `form:"name"`
+		Attachment *FormFile `form:"attachment"`
+	}
+	restErr := d.Decode(httptest.NewRecorder(), req, &dst)
+	require.Nil(t, restErr)
+	assert.Equal(t, "orders", dst.Name)
+	require.NotNil(t, dst.Attachment)
+	assert.Equal(t, "orders.csv", dst.Attachment.Filename)
+	assert.Equal(t, int64(15), dst.Attachment.Size)
+
+	f, err := dst.Attachment.Open()
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "id,amount\n1,42\n", string(content))
+
+	// The temporary file is removed once the request context is done
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(dst.Attachment.Path)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMultipartCodecCleanup(t *testing.T) {
+	newRequest := func(fields map[string]string) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("attachment", "orders.csv")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("id,amount\n1,42\n"))
+		require.NoError(t, err)
+		for name, value := range fields {
+			require.NoError(t, mw.WriteField(name, value))
+		}
+		require.NoError(t, mw.Close())
+
+		// The request context is never cancelled, so only the explicit
+		// removal deletes the files
+		req := httptest.NewRequest(http.MethodPost, "/", &body).WithContext(context.WithoutCancel(context.Background()))
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+	tempFiles := func(dir string) []os.DirEntry {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		return entries
+	}
+
+	type upload struct {
+		Partitions int       `form:"partitions"`
+		Attachment *FormFile `form:"attachment"`
+	}
+
+	t.Run("rejected request", func(t *testing.T) {
+		d := newAllMediaTypesDecoder()
+		d.TempDir = t.TempDir()
+
+		var dst upload
+		restErr := d.Decode(httptest.NewRecorder(), newRequest(map[string]string{"partitions": "three"}), &dst)
+		require.NotNil(t, restErr)
+		assert.Empty(t, tempFiles(d.TempDir))
+	})
+
+	t.Run("RemoveFormFiles", func(t *testing.T) {
+		d := newAllMediaTypesDecoder()
+		d.TempDir = t.TempDir()
+
+		var dst upload
+		restErr := d.Decode(httptest.NewRecorder(), newRequest(map[string]string{"partitions": "3"}), &dst)
+		require.Nil(t, restErr)
+		assert.Len(t, tempFiles(d.TempDir), 1)
+
+		require.NoError(t, RemoveFormFiles(&dst))
+		assert.Empty(t, tempFiles(d.TempDir))
+	})
+}
@@ -2,20 +2,55 @@ package rest
 
 import (
 	"flag"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config for a HTTP server
 type Config struct {
 	ServerGracefulShutdownTimeout time.Duration `yaml:"gracefulShutdownTimeout"`
+	// ServerShutdownDrainDelay is the time between failing the readiness probe
+	// and shutting down the server, so that load balancers can stop sending
+	// traffic before connections are closed.
+	ServerShutdownDrainDelay time.Duration `yaml:"shutdownDrainDelay"`
+
+	// GracefulRestart restarts the server without downtime on SIGHUP: a new
+	// process of the same executable is started with the listeners handed
+	// over. Once its readiness probe passes within GracefulRestartTimeout, the
+	// current process shuts down gracefully. The new process is started as a
+	// child of the current one; under systemd it must become the main process
+	// (PIDFile= or NotifyAccess=), otherwise the default KillMode=control-group
+	// kills it when the old main process exits.
+	GracefulRestart        bool          `yaml:"gracefulRestart"`
+	GracefulRestartTimeout time.Duration `yaml:"gracefulRestartTimeout"`
 
 	HTTPListenAddress      string        `yaml:"listenAddress"`
 	HTTPListenPort         int           `yaml:"listenPort"`
 	HTTPServerReadTimeout  time.Duration `yaml:"readTimeout"`
 	HTTPServerWriteTimeout time.Duration `yaml:"writeTimeout"`
 	HTTPServerIdleTimeout  time.Duration `yaml:"idleTimeout"`
+	// HTTPServerReadHeaderTimeout is the time to read the request headers. It
+	// protects against clients that send headers very slowly (slowloris).
+	HTTPServerReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	// HTTPServerMaxHeaderBytes is the maximum size of the request headers,
+	// including the request line, for HTTP/1 and HTTP/2.
+	HTTPServerMaxHeaderBytes int `yaml:"maxHeaderBytes"`
+
+	// HTTPListenUnixSocket is the path of a Unix domain socket the server
+	// listens on instead of the TCP listen address and port.
+	HTTPListenUnixSocket string `yaml:"listenUnixSocket"`
+	// HTTPListenUnixSocketMode is the octal file mode of the Unix domain
+	// socket, e.g. "0660".
+	HTTPListenUnixSocketMode string `yaml:"listenUnixSocketMode"`
+
+	// SystemdSocketActivation uses the socket passed by systemd (LISTEN_FDS) if
+	// the process has been socket activated. SystemdSocketName selects the
+	// socket by its FileDescriptorName, otherwise the first socket is used.
+	SystemdSocketActivation bool   `yaml:"systemdSocketActivation"`
+	SystemdSocketName       string `yaml:"systemdSocketName"`
 
 	// HTTPSListenPort and HTTPListenPort are separate so that we can listen
 	// on both and redirect users to the HTTPS url.
@@ -26,6 +61,9 @@ type Config struct {
 	// Go application remaps this to 443 you want to set this port to
 	// 443 as well. Otherwise, users will be redirected to your HTTPSListenPort.
 	AdvertisedHTTPSListenPort int `yaml:"advertisedHttpsListenPort"`
+	// Redirect configures the server that redirects HTTP requests to HTTPS
+	// if TLS is enabled.
+	Redirect RedirectConfig `yaml:"redirect"`
 
 	CompressionLevel int `yaml:"compressionLevel"`
 
@@ -33,17 +71,40 @@ type Config struct {
 	SetBasePathFromXForwardedPrefix bool   `yaml:"setBasePathFromXForwardedPrefix"`
 	StripPrefix                     bool   `yaml:"stripPrefix"`
 
+	// AdminListenAddress and AdminListenPort configure the internal admin
+	// listener which serves metrics, pprof, build info and the health probes.
+	// It is disabled if the port is 0. The endpoints are not authenticated and
+	// pprof exposes the process arguments, so the address defaults to
+	// 127.0.0.1 (also if empty). Use 0.0.0.0 to listen on all interfaces.
+	AdminListenAddress string `yaml:"adminListenAddress"`
+	AdminListenPort    int    `yaml:"adminListenPort"`
+
+	// HealthPathPrefix is the path under which the liveness, readiness and
+	// startup probes are served on the public listener (e.g. /health/ready),
+	// in front of the router. Empty disables them, which is the default; the
+	// admin listener serves them below this prefix or /health regardless.
+	HealthPathPrefix string `yaml:"healthPathPrefix"`
+
+	HTTP2 HTTP2Config `yaml:"http2"`
+
 	TLS TLSConfig `yaml:"tls"`
 }
 
 // RegisterFlags adds the flags required to config the server
 func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&c.ServerGracefulShutdownTimeout, "server.graceful-shutdown-timeout", 30*time.Second, "Timeout for graceful shutdowns")
+	f.BoolVar(&c.GracefulRestart, "server.graceful-restart", false, "Restart without downtime on SIGHUP by handing over the listeners to a new process of the same executable. Under systemd the new process must be tracked as main process (PIDFile= or NotifyAccess=), otherwise it is killed when the old process exits.")
+	f.DurationVar(&c.GracefulRestartTimeout, "server.graceful-restart-timeout", 60*time.Second, "Time the new process has to become ready during a graceful restart")
+	f.DurationVar(&c.ServerShutdownDrainDelay, "server.shutdown-drain-delay", 0, "Time between failing the readiness probe and shutting down the server, so that load balancers can drain traffic")
 
 	f.StringVar(&c.HTTPListenAddress, "server.http.listen-address", "", "HTTP server listen address")
 	f.IntVar(&c.HTTPListenPort, "server.http.listen-port", 8080, "HTTP server listen port")
 
 	f.IntVar(&c.HTTPSListenPort, "server.https.listen-port", 8081, "HTTPS server listen port")
+	f.StringVar(&c.HTTPListenUnixSocket, "server.http.listen-unix-socket", "", "Path of a Unix domain socket to listen on instead of the TCP listen address and port")
+	f.StringVar(&c.HTTPListenUnixSocketMode, "server.http.listen-unix-socket-mode", "0660", "Octal file mode of the Unix domain socket")
+	f.BoolVar(&c.SystemdSocketActivation, "server.systemd-socket-activation", true, "Listen on the socket passed by systemd if the process has been socket activated")
+	f.StringVar(&c.SystemdSocketName, "server.systemd-socket-name", "", "FileDescriptorName of the systemd socket to listen on. The first socket is used if empty.")
 
 	// Get "PORT" environment variable because CloudRun tells us what Port to use
 	portEnv := os.Getenv("PORT")
@@ -58,6 +119,8 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&c.HTTPServerReadTimeout, "server.http.read-timeout", 30*time.Second, "Read timeout for HTTP server")
 	f.DurationVar(&c.HTTPServerWriteTimeout, "server.http.write-timeout", 30*time.Second, "Write timeout for HTTP server")
 	f.DurationVar(&c.HTTPServerIdleTimeout, "server.http.idle-timeout", 120*time.Second, "Idle timeout for HTTP server")
+	f.DurationVar(&c.HTTPServerReadHeaderTimeout, "server.http.read-header-timeout", 10*time.Second, "Timeout for reading the request headers")
+	f.IntVar(&c.HTTPServerMaxHeaderBytes, "server.http.max-header-bytes", http.DefaultMaxHeaderBytes, "Maximum size of the request headers in bytes")
 
 	f.IntVar(&c.CompressionLevel, "server.compression-level", 4, "Compression level applied to all http responses. Valid values are: 0-9 (0=completely disable compression middleware, 1=weakest compression, 9=best compression)")
 
@@ -65,25 +128,137 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.SetBasePathFromXForwardedPrefix, "server.set-base-path-from-x-forwarded-prefix", true, "When set to true, Kowl will use the 'X-Forwarded-Prefix' header as the base path. (When enabled the 'base-path' setting won't be used)")
 	f.BoolVar(&c.StripPrefix, "server.strip-prefix", true, "If a base-path is set (either by the 'base-path' setting, or by the 'X-Forwarded-Prefix' header), they will be removed from the request url. You probably want to leave this enabled, unless you are using a proxy that can remove the prefix automatically (like Traefik's 'StripPrefix' option)")
 
+	f.StringVar(&c.AdminListenAddress, "server.admin.listen-address", "127.0.0.1", "Admin server listen address. The admin server serves metrics, pprof (including the process arguments) and build info without authentication, so only expose it to trusted networks.")
+	f.IntVar(&c.AdminListenPort, "server.admin.listen-port", 0, "Admin server listen port, which serves metrics, pprof, build info and health probes. 0 disables the admin server.")
+
+	f.StringVar(&c.HealthPathPrefix, "server.health-path-prefix", "", "Path under which the liveness (live), readiness (ready) and startup (startup) probes are served on the public listener, shadowing routes of the router. Empty disables them; the admin listener always serves them (under /health by default).")
+
+	c.HTTP2.RegisterFlagsWithPrefix(f, "server.http2.")
+	c.Redirect.RegisterFlagsWithPrefix(f, "server.redirect.")
 	c.TLS.RegisterFlagsWithPrefix(f, "server.tls.")
 }
 
 func (c *Config) SetDefaults() {
 	c.ServerGracefulShutdownTimeout = 30 * time.Second
+	c.ServerShutdownDrainDelay = 0
+	c.GracefulRestart = false
+	c.GracefulRestartTimeout = 60 * time.Second
 
 	c.HTTPListenAddress = ""
 	c.HTTPListenPort = 8080
 	c.HTTPServerIdleTimeout = 30 * time.Second
 	c.HTTPServerReadTimeout = 30 * time.Second
 	c.HTTPServerWriteTimeout = 30 * time.Second
+	c.HTTPServerReadHeaderTimeout = 10 * time.Second
+	c.HTTPServerMaxHeaderBytes = http.DefaultMaxHeaderBytes
 
 	c.HTTPSListenPort = 8081
+	c.HTTPListenUnixSocket = ""
+	c.HTTPListenUnixSocketMode = "0660"
+	c.SystemdSocketActivation = true
+	c.SystemdSocketName = ""
 
 	c.CompressionLevel = 4
 
 	c.BasePath = ""
 	c.SetBasePathFromXForwardedPrefix = true
 	c.StripPrefix = true
+
+	c.AdminListenAddress = "127.0.0.1"
+	c.AdminListenPort = 0
+
+	c.HealthPathPrefix = ""
+
+	c.HTTP2.SetDefaults()
+	c.Redirect.SetDefaults()
+	c.TLS.HSTS.SetDefaults()
+}
+
+// HTTP2Config contains the configuration properties for HTTP/2. HTTP/2 is
+// always enabled for TLS connections.
+type HTTP2Config struct {
+	// H2CEnabled enables HTTP/2 without TLS (h2c) with prior knowledge, e.g.
+	// for service meshes that speak h2c to upstreams.
+	H2CEnabled bool `yaml:"h2cEnabled"`
+	// MaxConcurrentStreams is the maximum number of concurrent streams per
+	// connection. Zero uses the Go default (currently 250).
+	MaxConcurrentStreams int `yaml:"maxConcurrentStreams"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config HTTP/2
+func (c *HTTP2Config) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.BoolVar(&c.H2CEnabled, prefix+"h2c-enabled", false, "Whether to serve HTTP/2 without TLS (h2c) with prior knowledge.")
+	f.IntVar(&c.MaxConcurrentStreams, prefix+"max-concurrent-streams", 0, "Maximum number of concurrent HTTP/2 streams per connection. 0 uses the Go default.")
+}
+
+func (c *HTTP2Config) SetDefaults() {
+	c.H2CEnabled = false
+	c.MaxConcurrentStreams = 0
+}
+
+// RedirectConfig contains the configuration properties for the HTTP to HTTPS
+// redirect server.
+type RedirectConfig struct {
+	// ListenAddress is the address the redirect server listens on together
+	// with the HTTPListenPort. It defaults to the HTTPListenAddress.
+	ListenAddress string `yaml:"listenAddress"`
+	// StatusCode of the redirect, either 301 (Moved Permanently) or 308
+	// (Permanent Redirect). Unlike 301, 308 preserves the request method and
+	// body.
+	StatusCode int `yaml:"statusCode"`
+	// OmitDefaultPort omits the port in the redirect URL if it is 443.
+	OmitDefaultPort bool `yaml:"omitDefaultPort"`
+	// ExemptPaths are served by the router over plain HTTP instead of being
+	// redirected. Paths ending with a slash exempt all paths below them. The
+	// health probes are always exempt.
+	ExemptPaths []string `yaml:"exemptPaths"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config the redirect server
+func (c *RedirectConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	c.ExemptPaths = []string{"/.well-known/acme-challenge/"}
+	f.StringVar(&c.ListenAddress, prefix+"listen-address", "", "HTTP to HTTPS redirect server listen address. Defaults to the HTTP listen address.")
+	f.IntVar(&c.StatusCode, prefix+"status-code", http.StatusMovedPermanently, "Status code of HTTP to HTTPS redirects, either 301 or 308")
+	f.BoolVar(&c.OmitDefaultPort, prefix+"omit-default-port", true, "Whether to omit the port in redirect URLs if it is 443")
+	f.Func(prefix+"exempt-paths", "Comma separated paths that are served over HTTP instead of being redirected (default \"/.well-known/acme-challenge/\")", func(value string) error {
+		c.ExemptPaths = nil
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				c.ExemptPaths = append(c.ExemptPaths, path)
+			}
+		}
+		return nil
+	})
+}
+
+func (c *RedirectConfig) SetDefaults() {
+	c.ListenAddress = ""
+	c.StatusCode = http.StatusMovedPermanently
+	c.OmitDefaultPort = true
+	c.ExemptPaths = []string{"/.well-known/acme-challenge/"}
+}
+
+// HSTSConfig contains the configuration properties for the
+// Strict-Transport-Security header, which is sent on TLS responses.
+type HSTSConfig struct {
+	// MaxAge is the time browsers only use HTTPS for the host. Zero disables
+	// the header.
+	MaxAge            time.Duration `yaml:"maxAge"`
+	IncludeSubdomains bool          `yaml:"includeSubdomains"`
+	Preload           bool          `yaml:"preload"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config HSTS
+func (c *HSTSConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.DurationVar(&c.MaxAge, prefix+"max-age", 0, "max-age of the Strict-Transport-Security header. 0 disables the header.")
+	f.BoolVar(&c.IncludeSubdomains, prefix+"include-subdomains", false, "Whether the Strict-Transport-Security header applies to all subdomains")
+	f.BoolVar(&c.Preload, prefix+"preload", false, "Whether to add the preload directive to the Strict-Transport-Security header")
+}
+
+func (c *HSTSConfig) SetDefaults() {
+	c.MaxAge = 0
+	c.IncludeSubdomains = false
+	c.Preload = false
 }
 
 // TLSConfig contains the configuration properties for the HTTP
@@ -93,6 +268,8 @@ type TLSConfig struct {
 	Enabled      bool   `yaml:"enabled"`
 	CertFilepath string `yaml:"certFilepath"`
 	KeyFilepath  string `yaml:"keyFilepath"`
+
+	HSTS HSTSConfig `yaml:"hsts"`
 }
 
 // RegisterFlagsWithPrefix adds the flags required to config the server
@@ -100,5 +277,6 @@ func (c *TLSConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
 	f.BoolVar(&c.Enabled, prefix+"enabled", false, "Whether to terminate TLS. Requires a key and cert filepath to be set.")
 	f.StringVar(&c.CertFilepath, prefix+"cert-filepath", "", "Filepath to TLS certificate.")
 	f.StringVar(&c.KeyFilepath, prefix+"key-filepath", "", "Filepath to TLS key.")
+	c.HSTS.RegisterFlagsWithPrefix(f, prefix+"hsts.")
 
 }
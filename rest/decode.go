@@ -1,88 +1,288 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/cloudhut/common/header"
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/cloudhut/common/header"
 )
 
+// DefaultMaxBodySize is the maximum request body size (1MB) that is accepted by
+// the default Decoder.
+const DefaultMaxBodySize int64 = 1 << 20
+
+// DefaultDecoder is the Decoder that is used by Decode unless another decoder
+// has been put on the request context via WithDecoder.
+var DefaultDecoder = NewDecoder()
+
+// Decoder decodes and validates request bodies. Use NewDecoder to create a
+// decoder with the default settings and adjust its fields as needed.
+type Decoder struct {
+	// MaxBodySize is the maximum number of bytes the request body may have.
+	// Zero or negative values disable the limit.
+	MaxBodySize int64
+	// AllowUnknownFields accepts request bodies that contain fields which do
+	// not exist in the destination object.
+	AllowUnknownFields bool
+	// MediaTypes is the list of accepted Content-Type values. The subtype may
+	// be a wildcard ("application/*") or a structured syntax suffix wildcard
+	// ("application/*+json"). Requests without a Content-Type header are
+	// always accepted.
+	MediaTypes []string
+	// UseNumber decodes numbers into a json.Number instead of a float64 when
+	// the destination is an interface{}.
+	UseNumber bool
+	// TempDir is the directory in which uploaded multipart files are stored.
+	// Defaults to the OS temp directory.
+	TempDir string
+
+	// codecs maps media types (which may contain wildcards) to the codec that
+	// decodes request bodies of that type.
+	codecs map[string]Codec
+}
+
+// NewDecoder creates a new Decoder which limits the request body to 1MB, accepts
+// "application/json" only and rejects unknown fields.
+//
+// Codecs for JSON ("application/json", "application/*+json"), YAML, url-encoded
+// forms and multipart forms are registered, but only JSON is accepted. Add the
+// respective media types to MediaTypes to accept other formats:
+//
+//	d := rest.NewDecoder()
+//	d.MediaTypes = append(d.MediaTypes, "application/yaml", "multipart/form-data")
+func NewDecoder() *Decoder {
+	d := &Decoder{
+		MaxBodySize:        DefaultMaxBodySize,
+		AllowUnknownFields: false,
+		MediaTypes:         []string{"application/json"},
+		UseNumber:          false,
+	}
+	d.registerDefaultCodecs()
+
+	return d
+}
+
+type decoderCtxKey struct{}
+
+// WithDecoder returns a middleware which makes Decode use the given decoder for
+// all requests it handles. This allows overriding the decoder settings for
+// single routes or route groups:
+//
+//	importDecoder := rest.NewDecoder()
+//	importDecoder.MaxBodySize = 50 << 20
+//	router.With(rest.WithDecoder(importDecoder)).Post("/import", handleImport)
+func WithDecoder(d *Decoder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), decoderCtxKey{}, d)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// DecoderFromContext returns the decoder that has been set via WithDecoder or
+// the DefaultDecoder.
+func DecoderFromContext(ctx context.Context) *Decoder {
+	if d, ok := ctx.Value(decoderCtxKey{}).(*Decoder); ok {
+		return d
+	}
+	return DefaultDecoder
+}
+
 // Decode tries to decode the request body into dst and calls its OK() function to validate the object.
-// It returns an error if:
+// The request is decoded with the decoder returned by DecoderFromContext, by default
+// it returns an error if:
 // - the content-type does not contain "application/json"
-// - body is smaller than 1MB
+// - body is larger than 1MB
 // - any unknown fields were set
 // - deserialization fails
+// - the Validate() method returns field violations
 // - the OK() method returns an error
+//
+// Field violations (either returned by Validate() or by OK() as ValidationErrors)
+// are listed in the violations array of the error response.
 func Decode(w http.ResponseWriter, r *http.Request, dst interface{}) *Error {
+	return DecoderFromContext(r.Context()).Decode(w, r, dst)
+}
+
+// Decode tries to decode the request body into dst using the codec that has
+// been registered for the request's Content-Type. Requests without Content-Type
+// are decoded as JSON. See the package level Decode function for the validation
+// that is applied.
+func (d *Decoder) Decode(w http.ResponseWriter, r *http.Request, dst interface{}) *Error {
+	mediaType := "application/json"
 	if r.Header.Get("Content-Type") != "" {
-		value, _ := header.ParseValueAndParams(r.Header, "Content-Type")
-		if value != "application/json" {
-			msg := "Content-Type header is not application/json"
+		mediaType, _ = header.ParseValueAndParams(r.Header, "Content-Type")
+		if !matchesAnyMediaType(d.MediaTypes, mediaType) {
+			msg := fmt.Sprintf("Content-Type header must be one of: %s", strings.Join(d.MediaTypes, ", "))
 			err := fmt.Errorf("wrong or missing Content-Type header value")
 			return &Error{Err: err, Status: http.StatusUnsupportedMediaType, Message: msg}
 		}
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	codec := d.codecFor(mediaType)
+	if codec == nil {
+		msg := fmt.Sprintf("Content-Type %s is not supported", mediaType)
+		err := fmt.Errorf("no codec registered for media type %q", mediaType)
+		return &Error{Err: err, Status: http.StatusUnsupportedMediaType, Message: msg}
+	}
+
+	if d.MaxBodySize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, d.MaxBodySize)
+	}
 
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
+	if err := codec.Decode(r, d, dst); err != nil {
+		return decodeError(err)
+	}
 
-	err := dec.Decode(&dst)
-	if err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
+	if restErr := validate(dst); restErr != nil {
+		// The handler never sees the files of rejected uploads
+		_ = RemoveFormFiles(dst)
+		return restErr
+	}
+	return nil
+}
 
-		switch {
-		case errors.As(err, &syntaxError):
-			msg := fmt.Sprintf("request body contains badly-formed JSON (at position %d)", syntaxError.Offset)
-			return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
+// decodeError converts an error returned by a codec into a REST error.
+func decodeError(err error) *Error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
+	var bindErr *bindError
 
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			msg := fmt.Sprintf("request body contains badly-formed JSON")
-			return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
+	if restErr, ok := unwrapError(err); ok {
+		return restErr
+	}
 
-		case errors.As(err, &unmarshalTypeError):
-			msg := fmt.Sprintf("Request body contains an invalid value for the %q field (at position %d)", unmarshalTypeError.Field, unmarshalTypeError.Offset)
-			return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
+	switch {
+	case errors.As(err, &maxBytesError):
+		msg := fmt.Sprintf("Request body must not be larger than %s", formatBytes(maxBytesError.Limit))
+		return &Error{Err: err, Status: http.StatusRequestEntityTooLarge, Message: msg}
 
-		case strings.HasPrefix(err.Error(), "json: unknown field "):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			msg := fmt.Sprintf("Request body contains unknown field %s", fieldName)
-			return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
+	case errors.As(err, &syntaxError):
+		msg := fmt.Sprintf("request body contains badly-formed JSON (at position %d)", syntaxError.Offset)
+		return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
 
-		case errors.Is(err, io.EOF):
-			msg := "Request body must not be empty"
-			return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		msg := fmt.Sprintf("request body contains badly-formed JSON")
+		return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
 
-		case err.Error() == "http: request body too large":
-			msg := "Request body must not be larger than 1MB"
-			return &Error{Err: err, Status: http.StatusRequestEntityTooLarge, Message: msg}
+	case errors.As(err, &unmarshalTypeError):
+		msg := fmt.Sprintf("Request body contains an invalid value for the %q field (at position %d)", unmarshalTypeError.Field, unmarshalTypeError.Offset)
+		violation := FieldViolation{
+			Field:   JSONPointer(strings.Split(unmarshalTypeError.Field, ".")...),
+			Rule:    "type",
+			Message: fmt.Sprintf("must be of type %v", unmarshalTypeError.Type),
+		}
+		return &Error{Err: err, Status: http.StatusBadRequest, Message: msg, Violations: []FieldViolation{violation}}
 
-		default:
-			msg := fmt.Sprintf("Unknown error while decoding the request: %v", err.Error())
-			return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
+	case errors.As(err, &bindErr):
+		msg := fmt.Sprintf("Request body contains an invalid value for the %q field", bindErr.Key)
+		violation := FieldViolation{
+			Field:   JSONPointer(bindErr.Key),
+			Rule:    "type",
+			Message: fmt.Sprintf("must be of type %v", bindErr.Type),
 		}
-	}
+		return &Error{Err: err, Status: http.StatusBadRequest, Message: msg, Violations: []FieldViolation{violation}}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		msg := fmt.Sprintf("Request body contains unknown field %s", fieldName)
+		return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
+
+	case errors.Is(err, io.EOF):
+		msg := "Request body must not be empty"
+		return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
 
-	err = dec.Decode(&struct{}{})
-	if err != io.EOF {
-		msg := "Request body must only contain a single JSON object"
+	default:
+		msg := fmt.Sprintf("Unknown error while decoding the request: %v", err.Error())
 		return &Error{Err: err, Status: http.StatusBadRequest, Message: msg}
 	}
+}
+
+// validate calls the Validate() and OK() methods of the decoded object if
+// they are implemented.
+func validate(dst interface{}) *Error {
+	if validator, ok := dst.(Validator); ok {
+		if violations := validator.Validate(); len(violations) > 0 {
+			err := ValidationErrors(violations)
+			return &Error{Err: err, Status: http.StatusBadRequest, Message: fmt.Sprintf("validating the decoded object failed: %v", err.Error()), Violations: violations}
+		}
+	}
 
 	if valid, ok := dst.(interface {
 		OK() error
 	}); ok {
-		err = valid.OK()
+		err := valid.OK()
 		if err != nil {
-			return &Error{Err: err, Status: http.StatusBadRequest, Message: fmt.Sprintf("validating the decoded object failed: %v", err.Error())}
+			var violations ValidationErrors
+			errors.As(err, &violations)
+			return &Error{Err: err, Status: http.StatusBadRequest, Message: fmt.Sprintf("validating the decoded object failed: %v", err.Error()), Violations: violations}
 		}
 	}
 
 	return nil
 }
+
+// matchesAnyMediaType returns true if the media type matches any of the given
+// patterns.
+func matchesAnyMediaType(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		if matchMediaType(pattern, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMediaType returns true if the media type (e.g. "application/vnd.api+json")
+// matches the pattern. Patterns support wildcards for the type ("*/*"), the
+// subtype ("application/*") and for the subtype with a structured syntax suffix
+// ("application/*+json").
+func matchMediaType(pattern string, mediaType string) bool {
+	pattern = strings.ToLower(pattern)
+	mediaType = strings.ToLower(mediaType)
+	if pattern == mediaType || pattern == "*/*" {
+		return true
+	}
+
+	patternType, patternSubtype, ok := strings.Cut(pattern, "/")
+	if !ok {
+		return false
+	}
+	mType, mSubtype, ok := strings.Cut(mediaType, "/")
+	if !ok || (patternType != "*" && patternType != mType) {
+		return false
+	}
+
+	switch {
+	case patternSubtype == "*":
+		return true
+	case strings.HasPrefix(patternSubtype, "*+"):
+		return strings.HasSuffix(mSubtype, patternSubtype[1:])
+	default:
+		return patternSubtype == mSubtype
+	}
+}
+
+// formatBytes formats a byte size as human-readable string, e.g. "1MB".
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", value), "0"), ".") + suffixes[i]
+}
@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type createTopicRequest struct {
+	Name           string `json:"name"`
+	PartitionCount int    `json:"partitionCount"`
+	CleanupPolicy  string `json:"cleanupPolicy"`
+}
+
+var topicNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+func (c *createTopicRequest) OK() error {
+	return Validate(
+		Required("/name", c.Name),
+		MaxLength("/name", c.Name, 10),
+		Match("/name", c.Name, topicNameRegexp),
+		Range("/partitionCount", c.PartitionCount, 1, 100),
+		Enum("/cleanupPolicy", c.CleanupPolicy, "delete", "compact"),
+	)
+}
+
+func TestDecodeValidation(t *testing.T) {
+	tests := []struct {
+		name               string
+		body               string
+		expectedViolations []FieldViolation
+	}{
+		{
+			name: "valid",
+			body: `{"name":"orders","partitionCount":3,"cleanupPolicy":"delete"}`,
+		},
+		{
+			name: "multiple violations",
+			body: `{"name":"orders topic!","partitionCount":0,"cleanupPolicy":"delete"}`,
+			expectedViolations: []FieldViolation{
+				{Field: "/name", Rule: "maxLength", Message: "must be at most 10 characters long"},
+				{Field: "/name", Rule: "pattern", Message: `must match the pattern "^[a-zA-Z0-9._-]+$"`},
+				{Field: "/partitionCount", Rule: "range", Message: "must be between 1 and 100"},
+			},
+		},
+		{
+			name: "required and enum",
+			body: `{"partitionCount":1,"cleanupPolicy":"remove"}`,
+			expectedViolations: []FieldViolation{
+				{Field: "/name", Rule: "required", Message: "must be set"},
+				{Field: "/name", Rule: "pattern", Message: `must match the pattern "^[a-zA-Z0-9._-]+$"`},
+				{Field: "/cleanupPolicy", Rule: "enum", Message: "must be one of [delete, compact]"},
+			},
+		},
+		{
+			name: "wrong type",
+			body: `{"name":"orders","partitionCount":"three","cleanupPolicy":"delete"}`,
+			expectedViolations: []FieldViolation{
+				{Field: "/partitionCount", Rule: "type", Message: "must be of type int"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/topics", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			var dst createTopicRequest
+			restErr := Decode(httptest.NewRecorder(), req, &dst)
+			if tt.expectedViolations == nil {
+				require.Nil(t, restErr)
+				return
+			}
+
+			require.NotNil(t, restErr)
+			assert.Equal(t, http.StatusBadRequest, restErr.Status)
+			assert.Equal(t, tt.expectedViolations, restErr.Violations)
+		})
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	assert.Equal(t, "", JSONPointer())
+	assert.Equal(t, "/topics/0/name", JSONPointer("topics", "0", "name"))
+	assert.Equal(t, "/a~1b/c~0d", JSONPointer("a/b", "c~d"))
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name           string
+		decoder        func() *Decoder
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:        "default decoder",
+			decoder:     NewDecoder,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"orders"}`,
+		},
+		{
+			name:           "default decoder rejects structured syntax suffix",
+			decoder:        NewDecoder,
+			contentType:    "application/vnd.api+json",
+			body:           `{"name":"orders"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "structured syntax suffix",
+			decoder: func() *Decoder {
+				d := NewDecoder()
+				d.MediaTypes = []string{"application/json", "application/*+json"}
+				return d
+			},
+			contentType: "application/vnd.api+json",
+			body:        `{"name":"orders"}`,
+		},
+		{
+			name:           "unknown fields rejected",
+			decoder:        NewDecoder,
+			body:           `{"name":"orders","replicas":3}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "unknown fields allowed",
+			decoder: func() *Decoder {
+				d := NewDecoder()
+				d.AllowUnknownFields = true
+				return d
+			},
+			body: `{"name":"orders","replicas":3}`,
+		},
+		{
+			name: "body too large",
+			decoder: func() *Decoder {
+				d := NewDecoder()
+				d.MaxBodySize = 8
+				return d
+			},
+			body:           `{"name":"orders"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var dst struct {
+				Name string `json:"name"`
+			}
+			restErr := tt.decoder().Decode(httptest.NewRecorder(), req, &dst)
+			if tt.expectedStatus == 0 {
+				require.Nil(t, restErr)
+				assert.Equal(t, "orders", dst.Name)
+				return
+			}
+			require.NotNil(t, restErr)
+			assert.Equal(t, tt.expectedStatus, restErr.Status)
+		})
+	}
+}
+
+func TestWithDecoder(t *testing.T) {
+	d := NewDecoder()
+	d.AllowUnknownFields = true
+
+	var restErr *Error
+	handler := WithDecoder(d)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var dst struct{}
+		restErr = Decode(w, r, &dst)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"orders"}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Nil(t, restErr)
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512B", formatBytes(512))
+	assert.Equal(t, "1MB", formatBytes(1<<20))
+	assert.Equal(t, "1.5KB", formatBytes(1536))
+	assert.Equal(t, "50MB", formatBytes(50<<20))
+}
@@ -0,0 +1,200 @@
+package rest
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// registerDefaultEncoders registers the built-in encoders for JSON, YAML and CSV.
+func (rs *Responder) registerDefaultEncoders() {
+	rs.RegisterEncoder("application/json", ResponseEncoderFunc(encodeJSON))
+	rs.RegisterEncoder("application/yaml", ResponseEncoderFunc(encodeYAML))
+	rs.RegisterEncoder("text/csv", ResponseEncoderFunc(encodeCSV))
+}
+
+// encodeJSON encodes data as JSON, indented if pretty output is requested.
+func encodeJSON(w io.Writer, data interface{}, opts EncodeOptions) error {
+	var jsonBytes []byte
+	var err error
+	if opts.Pretty {
+		jsonBytes, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		jsonBytes, err = json.Marshal(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(jsonBytes)
+	return err
+}
+
+// encodeYAML encodes data as YAML. The data is marshalled to JSON first, so
+// that the json struct tags and custom JSON marshallers of the response types
+// apply and the field order is preserved.
+func encodeYAML(w io.Writer, data interface{}, _ EncodeOptions) error {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML, hence we can parse it into a YAML node tree and
+	// render the tree using the block style.
+	var node yaml.Node
+	if err := yaml.Unmarshal(jsonBytes, &node); err != nil {
+		return err
+	}
+	resetYAMLStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// resetYAMLStyle removes the flow and quoting styles from all nodes, so that the
+// encoder renders block style and only quotes strings where required.
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// encodeCSV encodes a slice of structs (or pointers to structs) as CSV with a
+// header row. Column names are taken from the `csv` struct tag, the `json`
+// struct tag or the field name. Fields tagged with "-" are omitted.
+func encodeCSV(w io.Writer, data interface{}, _ EncodeOptions) error {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ErrNotEncodable
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return ErrNotEncodable
+	}
+
+	elemType := v.Type().Elem()
+	if elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return ErrNotEncodable
+	}
+
+	columns := csvColumns(elemType)
+	cw := csv.NewWriter(w)
+
+	headerRow := make([]string, len(columns))
+	for i, column := range columns {
+		headerRow[i] = column.name
+	}
+	if err := cw.Write(headerRow); err != nil {
+		return err
+	}
+
+	row := make([]string, len(columns))
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if elem.Kind() == reflect.Pointer {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+		for j, column := range columns {
+			field, err := elem.FieldByIndexErr(column.index)
+			if err != nil {
+				// Nil embedded struct pointer
+				row[j] = ""
+				continue
+			}
+			row[j] = csvValue(field)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+func csvColumns(t reflect.Type) []csvColumn {
+	fields := reflect.VisibleFields(t)
+	columns := make([]csvColumn, 0, len(fields))
+	for _, f := range fields {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name := f.Name
+		tag, ok := f.Tag.Lookup("csv")
+		if !ok {
+			tag, ok = f.Tag.Lookup("json")
+		}
+		if ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		columns = append(columns, csvColumn{name: name, index: f.Index})
+	}
+	return columns
+}
+
+// csvValue formats a single field value for a CSV cell.
+func csvValue(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.CanInterface() {
+		return ""
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return val.String()
+	case encoding.TextMarshaler:
+		text, err := val.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		jsonBytes, err := json.Marshal(v.Interface())
+		if err == nil {
+			return string(jsonBytes)
+		}
+	}
+
+	return fmt.Sprint(v.Interface())
+}
@@ -1,12 +1,149 @@
 package rest
 
-import "log/slog"
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
 
 // Error must be created to issue a REST compliant error
 type Error struct {
-	Err          error      `json:"-"`
-	Status       int        `json:"statusCode"`
-	Message      string     `json:"message"`
+	Err          error       `json:"-"`
+	Status       int         `json:"statusCode"`
+	Message      string      `json:"message"`
 	InternalLogs []slog.Attr `json:"-"`
-	IsSilent     bool       `json:"-"`
+	IsSilent     bool        `json:"-"`
+	// Violations lists the individual fields which caused the error, e.g. if
+	// the validation of a request body failed.
+	Violations []FieldViolation `json:"violations,omitempty"`
+
+	// The following fields are optional and only rendered if the client
+	// accepts RFC 9457 problem details (application/problem+json).
+
+	// Type is a URI reference that identifies the problem type. Defaults to
+	// "about:blank".
+	Type string `json:"-"`
+	// Title is a short, human-readable summary of the problem type. Defaults
+	// to the status text of the status code if Type is not set.
+	Title string `json:"-"`
+	// Detail is a human-readable explanation specific to this occurrence of
+	// the problem. Defaults to Message.
+	Detail string `json:"-"`
+	// Instance is a URI reference that identifies the specific occurrence of
+	// the problem.
+	Instance string `json:"-"`
+	// Extensions are additional members that are added to the problem details
+	// object. Members that collide with the standard members are ignored.
+	Extensions map[string]any `json:"-"`
+}
+
+// WrapError returns an error that carries the REST error, so that it can be
+// passed through functions returning an error (e.g. a Codec). It returns nil
+// if restErr is nil.
+//
+// *Error deliberately does not implement the error interface: a nil *Error
+// returned as error would be a non-nil error.
+func WrapError(restErr *Error) error {
+	if restErr == nil {
+		return nil
+	}
+	return wrappedError{restErr: restErr}
+}
+
+// unwrapError returns the REST error that has been wrapped into err by
+// WrapError.
+func unwrapError(err error) (*Error, bool) {
+	var wrapped wrappedError
+	if !errors.As(err, &wrapped) {
+		return nil, false
+	}
+	return wrapped.restErr, true
+}
+
+// wrappedError carries a REST error as error. It unwraps to the REST error's
+// cause, so that errors.Is and errors.As see the underlying error.
+type wrappedError struct {
+	restErr *Error
+}
+
+func (e wrappedError) Error() string {
+	if e.restErr.Err != nil {
+		return e.restErr.Err.Error()
+	}
+	return e.restErr.Message
+}
+
+func (e wrappedError) Unwrap() error {
+	return e.restErr.Err
+}
+
+// ProblemDetails returns the RFC 9457 representation of the error.
+func (e *Error) ProblemDetails() ProblemDetails {
+	p := ProblemDetails{
+		Type:       e.Type,
+		Title:      e.Title,
+		Status:     e.Status,
+		Detail:     e.Detail,
+		Instance:   e.Instance,
+		Extensions: e.Extensions,
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" && p.Type == "about:blank" {
+		p.Title = http.StatusText(e.Status)
+	}
+	if p.Detail == "" {
+		p.Detail = e.Message
+	}
+	if len(e.Violations) > 0 {
+		if _, exists := p.Extensions["violations"]; !exists {
+			extensions := make(map[string]any, len(p.Extensions)+1)
+			for k, v := range p.Extensions {
+				extensions[k] = v
+			}
+			extensions["violations"] = e.Violations
+			p.Extensions = extensions
+		}
+	}
+
+	return p
+}
+
+// ProblemDetails is a machine-readable error response as specified in RFC 9457.
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// MarshalJSON renders the problem details object including its extension
+// members.
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		switch k {
+		case "type", "title", "status", "detail", "instance":
+			continue
+		}
+		members[k] = v
+	}
+
+	members["type"] = p.Type
+	members["status"] = p.Status
+	if p.Title != "" {
+		members["title"] = p.Title
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+
+	return json.Marshal(members)
 }
@@ -0,0 +1,299 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHealthCheckTimeout is the timeout of health checks that do not set
+// their own.
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// Probe is the kind of health probe a check participates in.
+type Probe int
+
+const (
+	// ProbeLiveness checks whether the process must be restarted. Only register
+	// checks for unrecoverable states here, never external dependencies.
+	ProbeLiveness Probe = 1 << iota
+	// ProbeReadiness checks whether the process can serve traffic.
+	ProbeReadiness
+	// ProbeStartup checks whether the process has finished starting. Once it
+	// passed it keeps passing.
+	ProbeStartup
+)
+
+// HealthCheck is a named check that components register at the HealthRegistry.
+type HealthCheck struct {
+	// Name identifies the check in the health report.
+	Name string
+	// Check returns an error if the component is unhealthy.
+	Check func(ctx context.Context) error
+	// Probes are the probes the check participates in. It defaults to
+	// ProbeReadiness.
+	Probes Probe
+	// Timeout after which the check is considered failed. It defaults to
+	// DefaultHealthCheckTimeout.
+	Timeout time.Duration
+	// Optional checks do not fail the probe. They are reported with status
+	// "warn" if they fail, but the probe still passes. Checks are critical by
+	// default.
+	Optional bool
+	// CacheTTL is the time a result is reused, so that expensive checks are not
+	// executed for every probe. Zero disables caching.
+	CacheTTL time.Duration
+}
+
+// Health statuses that are reported in a HealthReport.
+const (
+	HealthStatusPass = "pass"
+	HealthStatusWarn = "warn"
+	HealthStatusFail = "fail"
+)
+
+// HealthReport is the result of a probe.
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult is the result of a single health check.
+type HealthCheckResult struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Optional  bool      `json:"optional"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+type registeredHealthCheck struct {
+	HealthCheck
+
+	mu         sync.Mutex
+	lastResult *HealthCheckResult
+}
+
+// HealthRegistry holds the health checks of all components and evaluates them
+// for liveness, readiness and startup probes.
+type HealthRegistry struct {
+	mu     sync.RWMutex
+	checks []*registeredHealthCheck
+
+	shuttingDown atomic.Bool
+	started      atomic.Bool
+}
+
+// NewHealthRegistry creates a new, empty HealthRegistry. All probes pass as
+// long as no checks have been registered.
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{}
+}
+
+// Register adds a health check. An error is returned if a check with the same
+// name has already been registered.
+func (h *HealthRegistry) Register(check HealthCheck) error {
+	if check.Name == "" || check.Check == nil {
+		return fmt.Errorf("health check must have a name and a check function")
+	}
+	if check.Probes == 0 {
+		check.Probes = ProbeReadiness
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultHealthCheckTimeout
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range h.checks {
+		if c.Name == check.Name {
+			return fmt.Errorf("health check %q has already been registered", check.Name)
+		}
+	}
+	h.checks = append(h.checks, &registeredHealthCheck{HealthCheck: check})
+	return nil
+}
+
+// SetShuttingDown lets the readiness probe fail, so that load balancers stop
+// sending new traffic. The server calls it as soon as the graceful shutdown
+// begins.
+func (h *HealthRegistry) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// IsShuttingDown returns true if the graceful shutdown has begun.
+func (h *HealthRegistry) IsShuttingDown() bool {
+	return h.shuttingDown.Load()
+}
+
+// Check evaluates all checks of the probe concurrently.
+func (h *HealthRegistry) Check(ctx context.Context, probe Probe) HealthReport {
+	if probe == ProbeStartup && h.started.Load() {
+		return HealthReport{Status: HealthStatusPass, Checks: []HealthCheckResult{}}
+	}
+
+	h.mu.RLock()
+	var checks []*registeredHealthCheck
+	for _, c := range h.checks {
+		if c.Probes&probe != 0 {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthStatusPass, Checks: results}
+	for _, result := range results {
+		if result.Status == HealthStatusFail {
+			report.Status = HealthStatusFail
+		} else if result.Status == HealthStatusWarn && report.Status == HealthStatusPass {
+			report.Status = HealthStatusWarn
+		}
+	}
+
+	if probe == ProbeReadiness && h.IsShuttingDown() {
+		report.Status = HealthStatusFail
+		report.Checks = append(report.Checks, HealthCheckResult{
+			Name:      "shutdown",
+			Status:    HealthStatusFail,
+			Error:     "server is shutting down",
+			Duration:  "0s",
+			CheckedAt: time.Now(),
+		})
+	}
+	if probe == ProbeStartup && report.Status != HealthStatusFail {
+		h.started.Store(true)
+	}
+
+	return report
+}
+
+// run executes the check or returns the cached result.
+func (c *registeredHealthCheck) run(ctx context.Context) HealthCheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lastResult != nil && time.Since(c.lastResult.CheckedAt) < c.CacheTTL {
+		return *c.lastResult
+	}
+
+	probeCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("health check timed out after %v", c.Timeout)
+	}
+
+	result := HealthCheckResult{
+		Name:      c.Name,
+		Status:    HealthStatusPass,
+		Optional:  c.Optional,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = HealthStatusFail
+		if c.Optional {
+			result.Status = HealthStatusWarn
+		}
+	}
+
+	// Results of cancelled probes say nothing about the component
+	if probeCtx.Err() == nil {
+		c.lastResult = &result
+	}
+	return result
+}
+
+// healthResponse is the body of the probe responses.
+type healthResponse struct {
+	Status string              `json:"status"`
+	Checks []healthCheckStatus `json:"checks"`
+}
+
+type healthCheckStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// Handler returns an HTTP handler that evaluates the probe. It responds with
+// 200 OK if the probe passes and 503 Service Unavailable otherwise, in both
+// cases with the names and statuses of the checks as JSON body.
+func (h *HealthRegistry) Handler(probe Probe) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context(), probe)
+
+		status := http.StatusOK
+		if report.Status == HealthStatusFail {
+			status = http.StatusServiceUnavailable
+		}
+		// Errors may contain internal details, so only names and statuses
+		// are exposed
+		response := healthResponse{Status: report.Status, Checks: make([]healthCheckStatus, len(report.Checks))}
+		for i, check := range report.Checks {
+			response.Checks[i] = healthCheckStatus{Name: check.Name, Status: check.Status}
+		}
+		body, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+}
+
+// healthEndpoints are the paths of the probes below the health path prefix.
+var healthEndpoints = map[string]Probe{
+	"live":    ProbeLiveness,
+	"ready":   ProbeReadiness,
+	"startup": ProbeStartup,
+}
+
+// newHealthHandler serves the probes below the prefix (e.g. /health/ready)
+// and passes all other requests to next. The probes are served in front of
+// the router, so that they are independent of its middlewares.
+func newHealthHandler(prefix string, registry *HealthRegistry, next http.Handler) http.Handler {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	handlers := make(map[string]http.Handler, len(healthEndpoints))
+	for name, probe := range healthEndpoints {
+		handlers[prefix+name] = registry.Handler(probe)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := handlers[r.URL.Path]; ok && slices.Contains([]string{http.MethodGet, http.MethodHead}, r.Method) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthRegistry(t *testing.T) {
+	registry := NewHealthRegistry()
+
+	var kafkaErr atomic.Pointer[error]
+	var kafkaCalls atomic.Int32
+	require.NoError(t, registry.Register(HealthCheck{
+		Name:     "kafka",
+		CacheTTL: time.Minute,
+		Check: func(ctx context.Context) error {
+			kafkaCalls.Add(1)
+			if err := kafkaErr.Load(); err != nil {
+				return *err
+			}
+			return nil
+		},
+	}))
+	require.NoError(t, registry.Register(HealthCheck{
+		Name:     "schema-registry",
+		Optional: true,
+		Timeout:  10 * time.Millisecond,
+		Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}))
+	require.NoError(t, registry.Register(HealthCheck{
+		Name:   "deadlock",
+		Probes: ProbeLiveness,
+		Check:  func(ctx context.Context) error { return nil },
+	}))
+	assert.Error(t, registry.Register(HealthCheck{Name: "kafka", Check: func(ctx context.Context) error { return nil }}))
+
+	// The optional check times out, which is reported but passes the probe
+	report := registry.Check(context.Background(), ProbeReadiness)
+	assert.Equal(t, HealthStatusWarn, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, HealthStatusPass, report.Checks[0].Status)
+	assert.Equal(t, HealthStatusWarn, report.Checks[1].Status)
+	assert.Equal(t, "health check timed out after 10ms", report.Checks[1].Error)
+
+	// The kafka result is cached
+	err := errors.New("no brokers reachable")
+	kafkaErr.Store(&err)
+	report = registry.Check(context.Background(), ProbeReadiness)
+	assert.Equal(t, HealthStatusWarn, report.Status)
+	assert.EqualValues(t, 1, kafkaCalls.Load())
+
+	report = registry.Check(context.Background(), ProbeLiveness)
+	assert.Equal(t, HealthStatusPass, report.Status)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "deadlock", report.Checks[0].Name)
+}
+
+func TestHealthHandler(t *testing.T) {
+	registry := NewHealthRegistry()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	handler := newHealthHandler("health", registry, next)
+
+	serve := func(target string) (*httptest.ResponseRecorder, HealthReport) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var report HealthReport
+		if rec.Code != http.StatusTeapot {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		}
+		return rec, report
+	}
+
+	rec, report := serve("/health/ready")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, HealthStatusPass, report.Status)
+
+	rec, _ = serve("/api/topics")
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+
+	registry.SetShuttingDown()
+	rec, report = serve("/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, HealthStatusFail, report.Status)
+
+	rec, _ = serve("/health/live")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Check errors are not exposed
+	require.NoError(t, registry.Register(HealthCheck{
+		Name:   "kafka",
+		Check:  func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.5:9092: connection refused") },
+		Probes: ProbeLiveness,
+	}))
+	rec, report = serve("/health/live")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "kafka", report.Checks[0].Name)
+	assert.NotContains(t, rec.Body.String(), "10.0.0.5")
+}
+
+func TestServerReadinessFailsOnShutdown(t *testing.T) {
+	var cfg Config
+	cfg.SetDefaults()
+	cfg.HTTPListenAddress = "127.0.0.1"
+	cfg.HTTPListenPort = freePort(t)
+	cfg.HealthPathPrefix = "/health"
+	cfg.ServerShutdownDrainDelay = 300 * time.Millisecond
+	srv, err := NewServer(&cfg, slog.Default(), chi.NewRouter())
+	require.NoError(t, err)
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", cfg.HTTPListenPort)
+	require.NoError(t, srv.Health.Register(HealthCheck{Name: "ok", Check: func(ctx context.Context) error { return nil }}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Run(ctx) }()
+
+	readyStatus := func() int {
+		res, err := http.Get(baseURL + "/health/ready")
+		if err != nil {
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	require.Eventually(t, func() bool { return readyStatus() == http.StatusOK }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	// The readiness probe fails while the server is still draining
+	assert.Eventually(t, func() bool { return readyStatus() == http.StatusServiceUnavailable }, 250*time.Millisecond, 10*time.Millisecond)
+	assert.NoError(t, <-errCh)
+}
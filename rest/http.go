@@ -5,22 +5,34 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
+
+	"github.com/cloudhut/common/header"
 )
 
-// SendResponse tries to send your data as JSON. If this fails it will print REST compliant errors
+// SendResponse tries to send your data in the representation that is negotiated from the
+// request's Accept header (JSON by default). If this fails it will print REST compliant errors.
+// See DefaultResponder for the supported media types.
 func SendResponse(w http.ResponseWriter, r *http.Request, logger *slog.Logger, status int, data interface{}) {
+	DefaultResponder.Send(w, r, logger, status, data)
+}
+
+// sendJSON marshals data and sends it using the given content type.
+func sendJSON(w http.ResponseWriter, r *http.Request, logger *slog.Logger, status int, contentType string, data interface{}) {
 	jsonBytes, err := json.Marshal(data)
 	if err != nil {
 		serverError(w, r, logger, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 	w.Write(jsonBytes)
 }
 
-// SendRESTError accepts a REST error which can be send to the user
+// SendRESTError accepts a REST error which can be send to the user. If the client
+// accepts "application/problem+json" the error is sent as RFC 9457 problem details,
+// otherwise the error is sent as JSON object with the status code and message.
 func SendRESTError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, restErr *Error) {
 	if !restErr.IsSilent {
 		logAttrs := []slog.Attr{
@@ -35,7 +47,34 @@ func SendRESTError(w http.ResponseWriter, r *http.Request, logger *slog.Logger,
 		logger.LogAttrs(r.Context(), slog.LevelError, "Sending REST error", logAttrs...)
 	}
 
-	SendResponse(w, r, logger, restErr.Status, restErr)
+	if acceptsProblemDetails(r) {
+		sendJSON(w, r, logger, restErr.Status, problemDetailsMediaType, restErr.ProblemDetails())
+		return
+	}
+	sendJSON(w, r, logger, restErr.Status, "application/json", restErr)
+}
+
+const problemDetailsMediaType = "application/problem+json"
+
+// acceptsProblemDetails returns true if the client explicitly accepts problem
+// details at least as much as JSON. The Accept header is negotiated like the
+// Responder does, so that media type parameters and q-values are respected.
+// Wildcards are not considered for problem details, so that existing clients
+// keep receiving the error format they know.
+func acceptsProblemDetails(r *http.Request) bool {
+	var problemQ, jsonQ float64
+	jsonSpecificity := -1
+	for _, spec := range header.ParseAcceptParams(r.Header, "Accept") {
+		if strings.EqualFold(spec.Value, problemDetailsMediaType) {
+			problemQ = max(problemQ, spec.Q)
+			continue
+		}
+		if specificity := mediaRangeSpecificity(spec.Value, "application/json"); specificity > jsonSpecificity {
+			jsonSpecificity = specificity
+			jsonQ = spec.Q
+		}
+	}
+	return problemQ > 0 && problemQ >= jsonQ
 }
 
 // ServerError prints a plain JSON error message
@@ -88,3 +127,14 @@ func HandleMethodNotAllowed(logger *slog.Logger) http.HandlerFunc {
 		SendRESTError(w, r, logger, restErr)
 	}
 }
+
+// MatchPath returns true if path equals one of the given paths or, for paths
+// ending with a slash, is located below it.
+func MatchPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
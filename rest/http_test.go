@@ -0,0 +1,238 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendRESTError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	restErr := &Error{
+		Err:        errors.New("topic does not exist"),
+		Status:     http.StatusNotFound,
+		Message:    "Topic not found",
+		Type:       "https://example.com/problems/topic-not-found",
+		Title:      "Topic not found",
+		Detail:     "Topic 'orders' does not exist",
+		Instance:   "/topics/orders",
+		Extensions: map[string]any{"topicName": "orders", "status": 500},
+	}
+
+	tests := []struct {
+		name                string
+		accept              string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "no accept header",
+			expectedContentType: "application/json",
+			expectedBody:        `{"statusCode":404,"message":"Topic not found"}`,
+		},
+		{
+			name:                "wildcard",
+			accept:              "*/*",
+			expectedContentType: "application/json",
+			expectedBody:        `{"statusCode":404,"message":"Topic not found"}`,
+		},
+		{
+			name:                "problem details",
+			accept:              "application/problem+json, application/json;q=0.9",
+			expectedContentType: "application/problem+json",
+			expectedBody: `{
+				"type": "https://example.com/problems/topic-not-found",
+				"title": "Topic not found",
+				"status": 404,
+				"detail": "Topic 'orders' does not exist",
+				"instance": "/topics/orders",
+				"topicName": "orders"
+			}`,
+		},
+		{
+			name:                "problem details with parameters",
+			accept:              "application/json;q=0.5, application/problem+json; charset=utf-8",
+			expectedContentType: "application/problem+json",
+			expectedBody: `{
+				"type": "https://example.com/problems/topic-not-found",
+				"title": "Topic not found",
+				"status": 404,
+				"detail": "Topic 'orders' does not exist",
+				"instance": "/topics/orders",
+				"topicName": "orders"
+			}`,
+		},
+		{
+			name:                "json preferred over problem details",
+			accept:              "application/problem+json;q=0.5, application/*;q=0.8",
+			expectedContentType: "application/json",
+			expectedBody:        `{"statusCode":404,"message":"Topic not found"}`,
+		},
+		{
+			name:                "problem details not acceptable",
+			accept:              "application/problem+json;q=0",
+			expectedContentType: "application/json",
+			expectedBody:        `{"statusCode":404,"message":"Topic not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/topics/orders", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			SendRESTError(rec, req, logger, restErr)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestProblemDetailsDefaults(t *testing.T) {
+	restErr := &Error{Status: http.StatusBadRequest, Message: "Invalid input"}
+
+	p := restErr.ProblemDetails()
+	assert.Equal(t, "about:blank", p.Type)
+	assert.Equal(t, "Bad Request", p.Title)
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, "Invalid input", p.Detail)
+}
+
+func TestSendResponseNegotiation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	type topic struct {
+		Name       string `json:"name"`
+		Partitions int    `json:"partitions"`
+		Internal   bool   `json:"-"`
+	}
+	topics := []topic{{Name: "orders", Partitions: 3}, {Name: "payments", Partitions: 1}}
+
+	tests := []struct {
+		name                string
+		target              string
+		accept              string
+		data                interface{}
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "no accept header",
+			target:              "/topics",
+			data:                topics,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `[{"name":"orders","partitions":3},{"name":"payments","partitions":1}]`,
+		},
+		{
+			name:                "pretty json via query parameter",
+			target:              "/topics?pretty",
+			data:                topics[0],
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        "{\n  \"name\": \"orders\",\n  \"partitions\": 3\n}",
+		},
+		{
+			name:                "pretty json via accept parameter",
+			target:              "/topics",
+			accept:              "application/json; pretty=true",
+			data:                topics[0],
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        "{\n  \"name\": \"orders\",\n  \"partitions\": 3\n}",
+		},
+		{
+			name:                "yaml",
+			target:              "/topics",
+			accept:              "application/yaml",
+			data:                topics[0],
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/yaml",
+			expectedBody:        "name: orders\npartitions: 3\n",
+		},
+		{
+			name:                "csv",
+			target:              "/topics",
+			accept:              "text/csv, application/json;q=0.5",
+			data:                topics,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedBody:        "name,partitions\norders,3\npayments,1\n",
+		},
+		{
+			name:                "csv not encodable falls back",
+			target:              "/topics",
+			accept:              "text/csv, application/json;q=0.5",
+			data:                topics[0],
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"name":"orders","partitions":3}`,
+		},
+		{
+			name:                "more specific range takes precedence",
+			target:              "/topics",
+			accept:              "application/*;q=0.1, application/yaml, */*;q=0.5",
+			data:                topics[0],
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/yaml",
+			expectedBody:        "name: orders\npartitions: 3\n",
+		},
+		{
+			name:                "wildcard excluding json",
+			target:              "/topics",
+			accept:              "*/*, application/json;q=0",
+			data:                topics[0],
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/yaml",
+			expectedBody:        "name: orders\npartitions: 3\n",
+		},
+		{
+			name:                "not acceptable",
+			target:              "/topics",
+			accept:              "application/xml",
+			data:                topics,
+			expectedStatus:      http.StatusNotAcceptable,
+			expectedContentType: "application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			SendResponse(rec, req, logger, http.StatusOK, tt.data)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"))
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	paths := []string{"/health", "/.well-known/acme-challenge/"}
+
+	assert.True(t, MatchPath(paths, "/health"))
+	assert.False(t, MatchPath(paths, "/health/ready"))
+	assert.True(t, MatchPath(paths, "/.well-known/acme-challenge/"))
+	assert.True(t, MatchPath(paths, "/.well-known/acme-challenge/token"))
+	assert.False(t, MatchPath(paths, "/.well-known/acme-challenge"))
+	assert.False(t, MatchPath(nil, "/health"))
+}
@@ -1,17 +1,148 @@
 package rest
 
 import (
+	"encoding/base64"
 	"net/http"
 	"strings"
 )
 
 // TokenFromHeader tries to retreive the token string from the
 // "Authorization" reqeust header: "Authorization: BEARER T".
+// The scheme is case-insensitive and surrounding whitespace is ignored.
 func TokenFromHeader(r *http.Request) string {
-	// Get token from authorization header.
-	bearer := r.Header.Get("Authorization")
-	if len(bearer) > 7 && strings.ToUpper(bearer[0:6]) == "BEARER" {
-		return bearer[7:]
+	return TokenFromAuthorization("Bearer")(r)
+}
+
+// Authorization is a parsed Authorization request header.
+type Authorization struct {
+	// Scheme is the authentication scheme as sent by the client, e.g. "Bearer".
+	Scheme string
+	// Credentials are the credentials following the scheme.
+	Credentials string
+}
+
+// ParseAuthorization parses the request's Authorization header into scheme and
+// credentials, which may be separated by spaces and tabs. It returns false if
+// the header is missing or has no credentials.
+func ParseAuthorization(r *http.Request) (Authorization, bool) {
+	value := strings.TrimSpace(r.Header.Get("Authorization"))
+	i := strings.IndexAny(value, " \t")
+	if i < 0 {
+		return Authorization{}, false
+	}
+	scheme := value[:i]
+	credentials := strings.TrimLeft(value[i:], " \t")
+	if scheme == "" || credentials == "" {
+		return Authorization{}, false
+	}
+	return Authorization{Scheme: scheme, Credentials: credentials}, true
+}
+
+// IsScheme returns true if the authorization uses the given scheme. Schemes
+// are compared case-insensitively.
+func (a Authorization) IsScheme(scheme string) bool {
+	return strings.EqualFold(a.Scheme, scheme)
+}
+
+// BasicAuth decodes the credentials of the "Basic" scheme.
+func (a Authorization) BasicAuth() (username string, password string, ok bool) {
+	if !a.IsScheme("Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(a.Credentials)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// TokenExtractor returns the token of a request or an empty string if the
+// request does not contain a token.
+type TokenExtractor func(r *http.Request) string
+
+// TokenExtractorChain returns the first token that is found by the extractors.
+func TokenExtractorChain(extractors ...TokenExtractor) TokenExtractor {
+	return func(r *http.Request) string {
+		for _, extract := range extractors {
+			if token := extract(r); token != "" {
+				return token
+			}
+		}
+		return ""
+	}
+}
+
+// TokenFromAuthorization returns the credentials of the Authorization header
+// if it uses the given scheme (e.g. "Bearer" or "Token").
+func TokenFromAuthorization(scheme string) TokenExtractor {
+	return func(r *http.Request) string {
+		auth, ok := ParseAuthorization(r)
+		if !ok || !auth.IsScheme(scheme) {
+			return ""
+		}
+		return auth.Credentials
+	}
+}
+
+// TokenFromCookie returns the value of the cookie with the given name.
+func TokenFromCookie(name string) TokenExtractor {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// TokenFromQuery returns the value of the query parameter with the given key.
+// Tokens in URLs end up in access logs and browser histories, so this should
+// only be used where no other option exists (e.g. EventSource requests).
+func TokenFromQuery(key string) TokenExtractor {
+	return func(r *http.Request) string {
+		return r.URL.Query().Get(key)
+	}
+}
+
+// TokenFromCustomHeader returns the value of the given request header, e.g.
+// "X-API-Key".
+func TokenFromCustomHeader(name string) TokenExtractor {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(name))
+	}
+}
+
+// TokenFromWebSocketProtocol returns a token that is sent as WebSocket
+// subprotocol, because browsers cannot set headers on WebSocket requests. Both
+// the token following the marker as separate protocol ("access_token, <token>"
+// with marker "access_token") and the token prefixed by the marker and a dot
+// ("access_token.<token>") are supported. A trailing dot of the marker is
+// ignored. Note that the server must not echo the token in the selected
+// subprotocol.
+func TokenFromWebSocketProtocol(marker string) TokenExtractor {
+	marker = strings.TrimSuffix(marker, ".")
+	return func(r *http.Request) string {
+		if marker == "" {
+			return ""
+		}
+		var protocols []string
+		for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+			for _, protocol := range strings.Split(value, ",") {
+				protocols = append(protocols, strings.TrimSpace(protocol))
+			}
+		}
+
+		for i, protocol := range protocols {
+			if protocol == marker {
+				if i+1 < len(protocols) {
+					return protocols[i+1]
+				}
+				return ""
+			}
+			if token, ok := strings.CutPrefix(protocol, marker+"."); ok && token != "" {
+				return token
+			}
+		}
+		return ""
 	}
-	return ""
 }
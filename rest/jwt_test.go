@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenFromHeader(t *testing.T) {
+	tests := []struct {
+		authorization string
+		expected      string
+	}{
+		{"Bearer abc", "abc"},
+		{"bearer abc  ", "abc"},
+		{"  BEARER   abc", "abc"},
+		{"Bearer\tabc", "abc"},
+		{"Bearer \t abc", "abc"},
+		{"Bearerabc", ""},
+		{"Bearer ", ""},
+		{"Basic abc", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.authorization, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			assert.Equal(t, tt.expected, TokenFromHeader(req))
+		})
+	}
+}
+
+func TestParseAuthorizationBasic(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("alice", "pass:word")
+
+	auth, ok := ParseAuthorization(req)
+	assert.True(t, ok)
+	assert.True(t, auth.IsScheme("basic"))
+
+	username, password, ok := auth.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "alice", username)
+	assert.Equal(t, "pass:word", password)
+}
+
+func TestTokenExtractorChain(t *testing.T) {
+	extract := TokenExtractorChain(
+		TokenFromHeader,
+		TokenFromCustomHeader("X-API-Key"),
+		TokenFromCookie("session"),
+		TokenFromWebSocketProtocol("access_token"),
+		TokenFromQuery("access_token"),
+	)
+
+	newRequest := func(modify func(r *http.Request)) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/?access_token=query", nil)
+		modify(req)
+		return req
+	}
+
+	assert.Equal(t, "header", extract(newRequest(func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer header")
+		r.AddCookie(&http.Cookie{Name: "session", Value: "cookie"})
+	})))
+	assert.Equal(t, "key", extract(newRequest(func(r *http.Request) { r.Header.Set("X-API-Key", "key") })))
+	assert.Equal(t, "cookie", extract(newRequest(func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: "session", Value: "cookie"})
+	})))
+	assert.Equal(t, "ws", extract(newRequest(func(r *http.Request) {
+		r.Header.Set("Sec-WebSocket-Protocol", "graphql-ws, access_token, ws")
+	})))
+	assert.Equal(t, "query", extract(newRequest(func(r *http.Request) {})))
+	assert.Equal(t, "prefixed", TokenFromWebSocketProtocol("access_token.")(newRequest(func(r *http.Request) {
+		r.Header.Set("Sec-WebSocket-Protocol", "graphql-ws, access_token.prefixed")
+	})))
+}
+
+func TestTokenFromWebSocketProtocol(t *testing.T) {
+	tests := []struct {
+		protocols string
+		expected  string
+	}{
+		{"graphql-ws, access_token, token", "token"},
+		{"graphql-ws, access_token.token", "token"},
+		{"access_token", ""},
+		{"access_token.", ""},
+		{"access_tokenX", ""},
+		{"access_tokens, token", ""},
+		{"graphql-ws", ""},
+	}
+	for _, marker := range []string{"access_token", "access_token."} {
+		for _, tt := range tests {
+			t.Run(marker+" "+tt.protocols, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Sec-WebSocket-Protocol", tt.protocols)
+				assert.Equal(t, tt.expected, TokenFromWebSocketProtocol(marker)(req))
+			})
+		}
+	}
+}
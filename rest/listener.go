@@ -0,0 +1,191 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// mainListenerName is the name of the main server's listener during graceful
+// restarts.
+const mainListenerName = "http"
+
+// listen returns the listener the server serves on. In order of precedence
+// this is the pre-opened Server.Listener, a listener inherited from the parent
+// process during a graceful restart, a listener passed by systemd socket
+// activation, a Unix domain socket or a TCP listener.
+func (s *Server) listen() (net.Listener, error) {
+	if s.Listener != nil {
+		return s.Listener, nil
+	}
+
+	listener, err := inheritedListener(s.listenerName())
+	if err != nil {
+		return nil, err
+	}
+	if listener != nil {
+		return listener, nil
+	}
+
+	if s.cfg.SystemdSocketActivation {
+		listener, err := systemdListener(s.cfg.SystemdSocketName)
+		if err != nil {
+			return nil, err
+		}
+		if listener != nil {
+			return listener, nil
+		}
+	}
+
+	if s.cfg.HTTPListenUnixSocket != "" {
+		return listenUnix(s.cfg.HTTPListenUnixSocket, s.cfg.HTTPListenUnixSocketMode)
+	}
+
+	listenerPort := s.cfg.HTTPListenPort
+	if s.cfg.TLS.Enabled {
+		listenerPort = s.cfg.HTTPSListenPort
+	}
+	return net.Listen("tcp", net.JoinHostPort(s.cfg.HTTPListenAddress, strconv.Itoa(listenerPort)))
+}
+
+// listenerName returns the name of the server's listener, which is used to
+// hand it over during graceful restarts.
+func (s *Server) listenerName() string {
+	if s.name == "" {
+		return mainListenerName
+	}
+	return s.name
+}
+
+// listenerAttrs returns the log attributes that describe the listener.
+func listenerAttrs(listener net.Listener) []any {
+	attrs := []any{slog.String("address", listener.Addr().String())}
+	if tcpAddr, ok := listener.Addr().(*net.TCPAddr); ok {
+		attrs = append(attrs, slog.Int("port", tcpAddr.Port))
+	} else {
+		attrs = append(attrs, slog.String("network", listener.Addr().Network()))
+	}
+	return attrs
+}
+
+// listenUnix listens on a Unix domain socket. A stale socket file that has been
+// left behind by a previous process is removed. mode is the octal file mode
+// of the socket (e.g. "0660"); the default permissions are kept if it is empty.
+func listenUnix(path string, mode string) (net.Listener, error) {
+	var perm fs.FileMode
+	if mode != "" {
+		parsed, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse unix socket mode %q: %w", mode, err)
+		}
+		perm = fs.FileMode(parsed)
+	}
+
+	if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		// Only remove sockets nobody is listening on anymore
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("unix socket %q is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket: %w", err)
+		}
+	}
+
+	if mode == "" {
+		return net.Listen("unix", path)
+	}
+	// The socket must not be accessible with the default permissions until
+	// the mode has been set
+	listener, err := listenUnixWithPerm(path, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set unix socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// systemdListenFDsStart is the first file descriptor passed by systemd.
+const systemdListenFDsStart = 3
+
+var systemdSockets struct {
+	once      sync.Once
+	listeners []net.Listener
+	names     []string
+	err       error
+}
+
+// systemdListener returns the listener that has been passed by systemd socket
+// activation (see sd_listen_fds(3)) with the given name (FileDescriptorName=
+// in the socket unit), or the first listener if name is empty. It returns nil
+// if the process has not been socket activated.
+func systemdListener(name string) (net.Listener, error) {
+	systemdSockets.once.Do(func() {
+		systemdSockets.listeners, systemdSockets.names, systemdSockets.err = systemdListeners()
+	})
+	if systemdSockets.err != nil {
+		return nil, systemdSockets.err
+	}
+	if len(systemdSockets.listeners) == 0 {
+		return nil, nil
+	}
+
+	if name == "" {
+		return systemdSockets.listeners[0], nil
+	}
+	for i, listenerName := range systemdSockets.names {
+		if listenerName == name {
+			return systemdSockets.listeners[i], nil
+		}
+	}
+	return nil, fmt.Errorf("systemd did not pass a socket named %q", name)
+}
+
+// systemdListeners creates listeners for all file descriptors passed by
+// systemd. The environment variables are unset, so that they are not
+// inherited by child processes.
+func systemdListeners() ([]net.Listener, []string, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+	listenerNames := make([]string, 0, count)
+	var errs []error
+	for i := 0; i < count; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(systemdListenFDsStart+i), name)
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to use systemd socket %d (%q): %w", i, name, err))
+			continue
+		}
+		listeners = append(listeners, listener)
+		listenerNames = append(listenerNames, name)
+	}
+	return listeners, listenerNames, errors.Join(errs...)
+}
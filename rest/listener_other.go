@@ -0,0 +1,15 @@
+//go:build !unix
+
+package rest
+
+import (
+	"io/fs"
+	"net"
+)
+
+// listenUnixWithPerm listens on a Unix domain socket. There is no umask on
+// this platform, the permissions are only applied after the socket has been
+// created.
+func listenUnixWithPerm(path string, _ fs.FileMode) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
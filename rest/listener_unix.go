@@ -0,0 +1,19 @@
+//go:build unix
+
+package rest
+
+import (
+	"io/fs"
+	"net"
+	"syscall"
+)
+
+// listenUnixWithPerm listens on a Unix domain socket that is created with at
+// most the given permissions, so that it is never accessible to users the
+// permissions exclude. The umask is process-wide, files created concurrently
+// by other goroutines are restricted as well while the socket is created.
+func listenUnixWithPerm(path string, perm fs.FileMode) (net.Listener, error) {
+	oldMask := syscall.Umask(int(^perm & fs.ModePerm))
+	defer syscall.Umask(oldMask)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package rest
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenUnixWithPerm(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "server.sock")
+	umask := syscall.Umask(0o022)
+	defer syscall.Umask(umask)
+
+	listener, err := listenUnixWithPerm(socketPath, 0o600)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	// The umask is restored
+	assert.Equal(t, 0o022, syscall.Umask(0o022))
+}
@@ -0,0 +1,227 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"reflect"
+)
+
+// FormFile is a file that has been uploaded as part of a multipart form. The
+// file content is streamed to a temporary file which is removed once the
+// request context is done. Move the file (or copy its content) if you need
+// to keep it. Handlers should defer RemoveFormFiles to remove the files as
+// soon as they return; the removal on context cancellation is only a fallback
+// which never runs if e.g. a middleware detached the request context with
+// context.WithoutCancel.
+//
+// Bind uploaded files by adding a field of type *FormFile or []*FormFile with
+// a `form:"name"` tag to the decoded struct.
+type FormFile struct {
+	// Filename is the filename that has been sent by the client. It must not
+	// be trusted as it may contain path separators.
+	Filename string
+	// Header is the MIME header of the form part.
+	Header textproto.MIMEHeader
+	// Size is the file size in bytes.
+	Size int64
+	// Path is the location of the temporary file on disk.
+	Path string
+}
+
+// ContentType returns the content type sent by the client for this file.
+func (f *FormFile) ContentType() string {
+	return f.Header.Get("Content-Type")
+}
+
+// Open opens the temporary file for reading.
+func (f *FormFile) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+// Remove deletes the temporary file.
+func (f *FormFile) Remove() error {
+	err := os.Remove(f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+var (
+	formFileType      = reflect.TypeOf(&FormFile{})
+	formFileSliceType = reflect.TypeOf([]*FormFile{})
+)
+
+// decodeMultipart streams a multipart form. Regular fields are bound like an
+// url-encoded form, file parts are written to temporary files and bound to
+// fields of type *FormFile or []*FormFile.
+//
+// The temporary files are removed right away if the request is rejected.
+func decodeMultipart(r *http.Request, d *Decoder, dst interface{}) (err error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		msg := fmt.Sprintf("request body contains a badly-formed multipart form: %v", err)
+		return WrapError(&Error{Err: err, Status: http.StatusBadRequest, Message: msg})
+	}
+
+	values := make(map[string][]string)
+	files := make(map[string][]*FormFile)
+	defer func() {
+		if err != nil {
+			removeFormFiles(files)
+			return
+		}
+		if len(files) > 0 {
+			registerFormFileCleanup(r.Context(), files)
+		}
+	}()
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(part)
+			part.Close()
+			if err != nil {
+				return err
+			}
+			values[name] = append(values[name], string(value))
+			continue
+		}
+
+		file, err := saveFormFile(part, d.TempDir)
+		part.Close()
+		if file != nil {
+			files[name] = append(files[name], file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(values) == 0 && len(files) == 0 {
+		return io.EOF
+	}
+
+	bound, err := bindValues(dst, "form", values)
+	if err != nil {
+		return err
+	}
+
+	err = walkFields(dst, "form", false, func(name string, _ string, field reflect.Value) error {
+		fieldFiles, ok := files[name]
+		if !ok {
+			return nil
+		}
+		switch field.Type() {
+		case formFileType:
+			field.Set(reflect.ValueOf(fieldFiles[0]))
+		case formFileSliceType:
+			field.Set(reflect.ValueOf(fieldFiles))
+		default:
+			return &bindError{Key: name, Type: field.Type(), Err: fmt.Errorf("file uploads can only be bound to *rest.FormFile or []*rest.FormFile")}
+		}
+		bound[name] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if d.AllowUnknownFields {
+		return nil
+	}
+	if err := checkUnknownFields(values, bound); err != nil {
+		return err
+	}
+	return checkUnknownFields(files, bound)
+}
+
+// saveFormFile streams the part into a new temporary file. The returned file
+// is non-nil if a temporary file has been created, even if an error occurred.
+func saveFormFile(part *multipart.Part, tempDir string) (*FormFile, error) {
+	f, err := os.CreateTemp(tempDir, "multipart-")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	formFile := &FormFile{
+		Filename: part.FileName(),
+		Header:   part.Header,
+		Path:     f.Name(),
+	}
+	formFile.Size, err = io.Copy(f, part)
+	if err != nil {
+		return formFile, err
+	}
+	return formFile, f.Close()
+}
+
+// RemoveFormFiles removes the temporary files of all uploaded files that have
+// been bound to dst. Defer it in handlers which decode multipart forms:
+//
+//	var params uploadParams
+//	if restErr := rest.Decode(w, r, &params); restErr != nil {
+//		...
+//	}
+//	defer rest.RemoveFormFiles(&params)
+func RemoveFormFiles(dst interface{}) error {
+	var errs []error
+	err := walkFields(dst, "form", false, func(_ string, _ string, field reflect.Value) error {
+		var fieldFiles []*FormFile
+		switch field.Type() {
+		case formFileType:
+			fieldFiles = []*FormFile{field.Interface().(*FormFile)}
+		case formFileSliceType:
+			fieldFiles = field.Interface().([]*FormFile)
+		}
+		for _, f := range fieldFiles {
+			if f == nil {
+				continue
+			}
+			if err := f.Remove(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// registerFormFileCleanup removes all temporary files once the request context
+// is done, which is the case as soon as the handler returns. It is the fallback
+// for handlers which do not call RemoveFormFiles.
+func registerFormFileCleanup(ctx context.Context, files map[string][]*FormFile) {
+	context.AfterFunc(ctx, func() {
+		removeFormFiles(files)
+	})
+}
+
+func removeFormFiles(files map[string][]*FormFile) {
+	for _, fieldFiles := range files {
+		for _, f := range fieldFiles {
+			_ = f.Remove()
+		}
+	}
+}
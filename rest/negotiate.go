@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cloudhut/common/header"
+)
+
+// ErrNotEncodable can be returned by a ResponseEncoder if it cannot represent
+// the given data (e.g. CSV for a non-tabular payload). The next best media type
+// accepted by the client is tried instead.
+var ErrNotEncodable = errors.New("data cannot be encoded in the requested media type")
+
+// EncodeOptions are passed to a ResponseEncoder.
+type EncodeOptions struct {
+	// Pretty is true if the client requested a human-readable output, either
+	// via the "pretty" query parameter or the "pretty" Accept parameter.
+	Pretty bool
+	// Params are the media type parameters of the matching Accept value.
+	Params map[string]string
+}
+
+// ResponseEncoder encodes response payloads into a specific media type.
+type ResponseEncoder interface {
+	Encode(w io.Writer, data interface{}, opts EncodeOptions) error
+}
+
+// ResponseEncoderFunc is an adapter to allow the use of ordinary functions as
+// ResponseEncoder.
+type ResponseEncoderFunc func(w io.Writer, data interface{}, opts EncodeOptions) error
+
+// Encode implements the ResponseEncoder interface.
+func (f ResponseEncoderFunc) Encode(w io.Writer, data interface{}, opts EncodeOptions) error {
+	return f(w, data, opts)
+}
+
+// DefaultResponder is the Responder that is used by SendResponse.
+var DefaultResponder = NewResponder()
+
+// Responder sends responses in the representation that is negotiated from the
+// request's Accept header.
+type Responder struct {
+	// mediaTypes lists the registered media types in order of the server's
+	// preference, which is used if the client has no preference.
+	mediaTypes []string
+	encoders   map[string]ResponseEncoder
+}
+
+// NewResponder creates a Responder with encoders for JSON ("application/json"),
+// YAML ("application/yaml") and CSV ("text/csv"). JSON is preferred if the client
+// does not send an Accept header or accepts any media type.
+func NewResponder() *Responder {
+	rs := &Responder{encoders: make(map[string]ResponseEncoder)}
+	rs.registerDefaultEncoders()
+	return rs
+}
+
+// RegisterEncoder registers (or replaces) the encoder for the given media type,
+// e.g. "application/msgpack". Newly registered media types have the lowest
+// server preference.
+func (rs *Responder) RegisterEncoder(mediaType string, enc ResponseEncoder) {
+	mediaType = strings.ToLower(mediaType)
+	if _, exists := rs.encoders[mediaType]; !exists {
+		rs.mediaTypes = append(rs.mediaTypes, mediaType)
+	}
+	rs.encoders[mediaType] = enc
+}
+
+// Send negotiates the response representation and sends data with the given
+// status. If none of the registered media types is acceptable for the client,
+// a 406 REST error is sent instead.
+func (rs *Responder) Send(w http.ResponseWriter, r *http.Request, logger *slog.Logger, status int, data interface{}) {
+	w.Header().Add("Vary", "Accept")
+
+	pretty := isPrettyRequested(r)
+	for _, candidate := range rs.negotiate(r.Header) {
+		opts := EncodeOptions{Pretty: pretty || isTruthy(candidate.params["pretty"]), Params: candidate.params}
+
+		var buf bytes.Buffer
+		err := rs.encoders[candidate.mediaType].Encode(&buf, data, opts)
+		if errors.Is(err, ErrNotEncodable) {
+			continue
+		}
+		if err != nil {
+			serverError(w, r, logger, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", candidate.mediaType)
+		w.WriteHeader(status)
+		w.Write(buf.Bytes())
+		return
+	}
+
+	restErr := &Error{
+		Err:      fmt.Errorf("no acceptable media type for Accept header %q", r.Header.Get("Accept")),
+		Status:   http.StatusNotAcceptable,
+		Message:  fmt.Sprintf("None of the requested media types is supported. Supported media types are: %s", strings.Join(rs.mediaTypes, ", ")),
+		IsSilent: true,
+	}
+	SendRESTError(w, r, logger, restErr)
+}
+
+type negotiatedMediaType struct {
+	mediaType string
+	params    map[string]string
+	q         float64
+}
+
+// negotiate returns the acceptable registered media types ordered by the
+// client's preference. For each media type the most specific matching Accept
+// value determines its quality (e.g. "text/csv" takes precedence over "text/*",
+// which takes precedence over "*/*"). Media types with the same quality are
+// ordered by the server's preference.
+func (rs *Responder) negotiate(h http.Header) []negotiatedMediaType {
+	specs := header.ParseAcceptParams(h, "Accept")
+	if len(specs) == 0 {
+		candidates := make([]negotiatedMediaType, len(rs.mediaTypes))
+		for i, mediaType := range rs.mediaTypes {
+			candidates[i] = negotiatedMediaType{mediaType: mediaType, q: 1}
+		}
+		return candidates
+	}
+
+	candidates := make([]negotiatedMediaType, 0, len(rs.mediaTypes))
+	for _, mediaType := range rs.mediaTypes {
+		bestSpecificity := -1
+		var best negotiatedMediaType
+		for _, spec := range specs {
+			specificity := mediaRangeSpecificity(spec.Value, mediaType)
+			if specificity > bestSpecificity {
+				bestSpecificity = specificity
+				best = negotiatedMediaType{mediaType: mediaType, params: spec.Params, q: spec.Q}
+			}
+		}
+		if bestSpecificity >= 0 && best.q > 0 {
+			candidates = append(candidates, best)
+		}
+	}
+
+	// Stable insertion sort by descending quality keeps the server preference
+	// for equal qualities.
+	for i := 1; i < len(candidates); i++ {
+		for j := i; j > 0 && candidates[j].q > candidates[j-1].q; j-- {
+			candidates[j], candidates[j-1] = candidates[j-1], candidates[j]
+		}
+	}
+
+	return candidates
+}
+
+// mediaRangeSpecificity returns how specific the media range (e.g. "text/*")
+// matches the media type: 2 for an exact match, 1 for a subtype wildcard, 0 for
+// "*/*" and -1 if it does not match.
+func mediaRangeSpecificity(mediaRange string, mediaType string) int {
+	mediaRange = strings.ToLower(mediaRange)
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*" || mediaRange == "*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		if strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")) {
+			return 1
+		}
+	}
+	return -1
+}
+
+// isPrettyRequested returns true if the "pretty" query parameter is set and not
+// explicitly disabled, e.g. "?pretty" or "?pretty=true".
+func isPrettyRequested(r *http.Request) bool {
+	values, ok := r.URL.Query()["pretty"]
+	if !ok {
+		return false
+	}
+	return values[0] == "" || isTruthy(values[0])
+}
+
+func isTruthy(val string) bool {
+	b, err := strconv.ParseBool(val)
+	return err == nil && b
+}
@@ -0,0 +1,129 @@
+package pagination
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cloudhut/common/rest"
+)
+
+// Page is the standard envelope for paginated list responses.
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// Limit is the page size that has been applied.
+	Limit int `json:"limit"`
+	// Offset is the offset of the first item for offset based pagination.
+	Offset int `json:"offset,omitempty"`
+	// TotalCount is the total number of items, if known.
+	TotalCount *int `json:"totalCount,omitempty"`
+	// NextPageToken is the page token of the next page for cursor based
+	// pagination. It is empty on the last page.
+	NextPageToken string `json:"nextPageToken,omitempty"`
+	// PrevPageToken is the page token of the previous page for cursor based
+	// pagination, if supported.
+	PrevPageToken string `json:"prevPageToken,omitempty"`
+	// HasMore is true if there are more items after this page.
+	HasMore bool `json:"hasMore"`
+}
+
+// NewOffsetPage creates a page for offset based pagination. totalCount is the
+// total number of items across all pages.
+func NewOffsetPage[T any](params Params, items []T, totalCount int) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return Page[T]{
+		Items:      items,
+		Limit:      params.Limit,
+		Offset:     params.Offset,
+		TotalCount: &totalCount,
+		HasMore:    params.Offset+len(items) < totalCount,
+	}
+}
+
+// NewCursorPage creates a page for cursor based pagination. Pass an empty next
+// page token on the last page and an empty previous page token if navigating
+// backwards is not supported.
+func NewCursorPage[T any](params Params, items []T, nextPageToken string, prevPageToken string) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return Page[T]{
+		Items:         items,
+		Limit:         params.Limit,
+		NextPageToken: nextPageToken,
+		PrevPageToken: prevPageToken,
+		HasMore:       nextPageToken != "",
+	}
+}
+
+// SendPage sends the page envelope and adds RFC 8288 Link headers for the
+// first, previous, next and (if the total count is known) last page. The links
+// are relative to the request URL and respect the base path the request has
+// been served under.
+func SendPage[T any](w http.ResponseWriter, r *http.Request, logger *slog.Logger, page Page[T]) {
+	for _, link := range Links(r, page) {
+		w.Header().Add("Link", link)
+	}
+	rest.SendResponse(w, r, logger, http.StatusOK, page)
+}
+
+// Links returns the RFC 8288 Link header values for the given page.
+func Links[T any](r *http.Request, page Page[T]) []string {
+	link := func(rel string, modify func(q url.Values)) string {
+		q := r.URL.Query()
+		q.Del(OffsetParam)
+		q.Del(PageTokenParam)
+		modify(q)
+
+		u := url.URL{Path: requestPath(r), RawQuery: q.Encode()}
+		return "<" + u.String() + `>; rel="` + rel + `"`
+	}
+
+	links := []string{link("first", func(url.Values) {})}
+
+	isCursorPage := page.NextPageToken != "" || page.PrevPageToken != ""
+	switch {
+	case isCursorPage:
+		if page.PrevPageToken != "" {
+			links = append(links, link("prev", func(q url.Values) { q.Set(PageTokenParam, page.PrevPageToken) }))
+		}
+		if page.NextPageToken != "" {
+			links = append(links, link("next", func(q url.Values) { q.Set(PageTokenParam, page.NextPageToken) }))
+		}
+	case page.Limit > 0:
+		if page.Offset > 0 {
+			prevOffset := max(page.Offset-page.Limit, 0)
+			links = append(links, link("prev", func(q url.Values) { setOffset(q, prevOffset) }))
+		}
+		if page.HasMore {
+			links = append(links, link("next", func(q url.Values) { setOffset(q, page.Offset+page.Limit) }))
+		}
+		if page.TotalCount != nil && *page.TotalCount > 0 {
+			lastOffset := (*page.TotalCount - 1) / page.Limit * page.Limit
+			links = append(links, link("last", func(q url.Values) { setOffset(q, lastOffset) }))
+		}
+	}
+
+	return links
+}
+
+func setOffset(q url.Values, offset int) {
+	if offset > 0 {
+		q.Set(OffsetParam, strconv.Itoa(offset))
+	}
+}
+
+// requestPath returns the path under which the client has requested the
+// resource, including the base path if it has been stripped.
+func requestPath(r *http.Request) string {
+	p := r.URL.Path
+	basePath := rest.BasePathFromContext(r.Context())
+	if basePath == "" || strings.HasPrefix(p, basePath) {
+		return p
+	}
+	return strings.TrimSuffix(basePath, "/") + p
+}
@@ -0,0 +1,180 @@
+// Package pagination provides helpers for paginated list endpoints. It parses
+// the limit, offset and page_token query parameters, encodes opaque cursor
+// tokens and sends a standard page envelope including RFC 8288 Link headers.
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudhut/common/rest"
+)
+
+const (
+	// LimitParam is the query parameter for the maximum number of items per page.
+	LimitParam = "limit"
+	// OffsetParam is the query parameter for the number of items to skip.
+	OffsetParam = "offset"
+	// PageTokenParam is the query parameter for the cursor of the requested page.
+	PageTokenParam = "page_token"
+)
+
+// Config for a Paginator.
+type Config struct {
+	// DefaultLimit is the page size that is used if no limit is requested.
+	DefaultLimit int
+	// MaxLimit is the largest page size a client may request.
+	MaxLimit int
+	// Secret is used to sign page tokens with HMAC-SHA256, so that clients
+	// cannot forge cursors. Page tokens are only encoded if it is empty.
+	Secret []byte
+}
+
+// SetDefaults sets the default limits. The secret is not set.
+func (c *Config) SetDefaults() {
+	c.DefaultLimit = 50
+	c.MaxLimit = 1000
+}
+
+// Paginator parses pagination parameters and encodes page tokens.
+type Paginator struct {
+	cfg Config
+}
+
+// NewPaginator creates a new Paginator. Limits that are not set fall back to
+// the defaults.
+func NewPaginator(cfg Config) *Paginator {
+	var defaults Config
+	defaults.SetDefaults()
+	if cfg.DefaultLimit <= 0 {
+		cfg.DefaultLimit = defaults.DefaultLimit
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = defaults.MaxLimit
+	}
+	if cfg.DefaultLimit > cfg.MaxLimit {
+		cfg.DefaultLimit = cfg.MaxLimit
+	}
+
+	return &Paginator{cfg: cfg}
+}
+
+// Params are the pagination parameters of a request.
+type Params struct {
+	// Limit is the maximum number of items of the requested page.
+	Limit int
+	// Offset is the number of items to skip. It is always zero if a page token
+	// has been sent.
+	Offset int
+	// PageToken is the raw page token sent by the client. Use DecodeCursor to
+	// decode it.
+	PageToken string
+}
+
+// ParseParams parses the limit, offset and page_token query parameters. A 400
+// REST error is returned if the limit is out of bounds, the offset is negative
+// or offset and page token are combined.
+func (p *Paginator) ParseParams(r *http.Request) (Params, *rest.Error) {
+	limit, restErr := rest.GetQueryParamInt(r, LimitParam, p.cfg.DefaultLimit)
+	if restErr != nil {
+		return Params{}, restErr
+	}
+	if limit < 1 || limit > p.cfg.MaxLimit {
+		return Params{}, &rest.Error{
+			Err:     fmt.Errorf("limit %d is out of bounds", limit),
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid value for query parameter %q: must be between 1 and %d", LimitParam, p.cfg.MaxLimit),
+		}
+	}
+
+	offset, restErr := rest.GetQueryParamInt(r, OffsetParam, 0)
+	if restErr != nil {
+		return Params{}, restErr
+	}
+	if offset < 0 {
+		return Params{}, &rest.Error{
+			Err:     fmt.Errorf("offset %d is negative", offset),
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid value for query parameter %q: must not be negative", OffsetParam),
+		}
+	}
+
+	pageToken, restErr := rest.ParseQueryParam(r, PageTokenParam, "", func(s string) (string, error) { return s, nil })
+	if restErr != nil {
+		return Params{}, restErr
+	}
+	if pageToken != "" && offset != 0 {
+		return Params{}, &rest.Error{
+			Err:     errors.New("offset and page token have been combined"),
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Query parameters %q and %q must not be combined", OffsetParam, PageTokenParam),
+		}
+	}
+
+	return Params{Limit: limit, Offset: offset, PageToken: pageToken}, nil
+}
+
+// EncodeCursor encodes the cursor (e.g. the sort key of the last item of a
+// page) into an opaque page token. The token is signed if a secret has been
+// configured.
+func (p *Paginator) EncodeCursor(cursor any) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(payload)
+	if len(p.cfg.Secret) == 0 {
+		return token, nil
+	}
+	return token + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload)), nil
+}
+
+// DecodeCursor decodes a page token that has been created with EncodeCursor into
+// dst. A 400 REST error is returned if the token is malformed or its signature
+// is invalid.
+func (p *Paginator) DecodeCursor(token string, dst any) *rest.Error {
+	invalidTokenErr := func(err error) *rest.Error {
+		return &rest.Error{
+			Err:     fmt.Errorf("failed to decode page token: %w", err),
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid value for query parameter %q", PageTokenParam),
+		}
+	}
+
+	encodedPayload, encodedSignature, signed := strings.Cut(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return invalidTokenErr(err)
+	}
+
+	if len(p.cfg.Secret) > 0 {
+		if !signed {
+			return invalidTokenErr(errors.New("page token is not signed"))
+		}
+		signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+		if err != nil {
+			return invalidTokenErr(err)
+		}
+		if !hmac.Equal(signature, p.sign(payload)) {
+			return invalidTokenErr(errors.New("page token signature is invalid"))
+		}
+	}
+
+	if err := json.Unmarshal(payload, dst); err != nil {
+		return invalidTokenErr(err)
+	}
+	return nil
+}
+
+func (p *Paginator) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.cfg.Secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,107 @@
+package pagination
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudhut/common/rest"
+)
+
+func TestParseParams(t *testing.T) {
+	p := NewPaginator(Config{DefaultLimit: 20, MaxLimit: 100})
+
+	params, restErr := p.ParseParams(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Nil(t, restErr)
+	assert.Equal(t, Params{Limit: 20}, params)
+
+	params, restErr = p.ParseParams(httptest.NewRequest(http.MethodGet, "/?limit=10&offset=30", nil))
+	require.Nil(t, restErr)
+	assert.Equal(t, Params{Limit: 10, Offset: 30}, params)
+
+	tests := []struct {
+		target          string
+		expectedMessage string
+	}{
+		{"/?limit=0", `Invalid value for query parameter "limit": must be between 1 and 100`},
+		{"/?limit=101", `Invalid value for query parameter "limit": must be between 1 and 100`},
+		{"/?offset=-1", `Invalid value for query parameter "offset": must not be negative`},
+		{"/?offset=10&page_token=abc", `Query parameters "offset" and "page_token" must not be combined`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			_, restErr := p.ParseParams(httptest.NewRequest(http.MethodGet, tt.target, nil))
+			require.NotNil(t, restErr)
+			assert.Equal(t, http.StatusBadRequest, restErr.Status)
+			assert.Equal(t, tt.expectedMessage, restErr.Message)
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	type cursor struct {
+		LastID string `json:"lastId"`
+	}
+
+	p := NewPaginator(Config{Secret: []byte("secret")})
+	token, err := p.EncodeCursor(cursor{LastID: "orders"})
+	require.NoError(t, err)
+
+	var decoded cursor
+	require.Nil(t, p.DecodeCursor(token, &decoded))
+	assert.Equal(t, cursor{LastID: "orders"}, decoded)
+
+	// Tokens signed with another secret or without signature must be rejected
+	forged, err := NewPaginator(Config{Secret: []byte("other")}).EncodeCursor(cursor{LastID: "payments"})
+	require.NoError(t, err)
+	assert.NotNil(t, p.DecodeCursor(forged, &decoded))
+
+	unsigned, err := NewPaginator(Config{}).EncodeCursor(cursor{LastID: "payments"})
+	require.NoError(t, err)
+	restErr := p.DecodeCursor(unsigned, &decoded)
+	require.NotNil(t, restErr)
+	assert.Equal(t, http.StatusBadRequest, restErr.Status)
+}
+
+func TestSendPage(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/topics?limit=10&offset=10&filter=a", nil)
+	req = req.WithContext(rest.ContextWithBasePath(req.Context(), "/console"))
+
+	p := NewPaginator(Config{})
+	params, restErr := p.ParseParams(req)
+	require.Nil(t, restErr)
+
+	rec := httptest.NewRecorder()
+	SendPage(rec, req, slog.Default(), NewOffsetPage(params, []string{"a", "b"}, 35))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{
+		`</console/topics?filter=a&limit=10>; rel="first"`,
+		`</console/topics?filter=a&limit=10>; rel="prev"`,
+		`</console/topics?filter=a&limit=10&offset=20>; rel="next"`,
+		`</console/topics?filter=a&limit=10&offset=30>; rel="last"`,
+	}, rec.Header().Values("Link"))
+
+	var page Page[string]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Equal(t, []string{"a", "b"}, page.Items)
+	assert.Equal(t, 10, page.Offset)
+	assert.True(t, page.HasMore)
+	require.NotNil(t, page.TotalCount)
+	assert.Equal(t, 35, *page.TotalCount)
+}
+
+func TestCursorPageLinks(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/topics?page_token=cur", nil)
+	links := Links(req, NewCursorPage(Params{Limit: 50, PageToken: "cur"}, []string{"a"}, "next", "prev"))
+	assert.Equal(t, []string{
+		`</topics>; rel="first"`,
+		`</topics?page_token=prev>; rel="prev"`,
+		`</topics?page_token=next>; rel="next"`,
+	}, links)
+}
@@ -0,0 +1,343 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// queryValues returns all values of the query parameter with the given key.
+// In contrast to url.Values it reports parameters that are not properly
+// escaped instead of silently dropping them.
+func queryValues(r *http.Request, key string) ([]string, *Error) {
+	var values []string
+	for _, pair := range strings.Split(r.URL.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		k, err := url.QueryUnescape(rawKey)
+		if err != nil || k != key {
+			continue
+		}
+		v, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, &Error{
+				Err:     fmt.Errorf("failed to unescape query parameter %q: %w", key, err),
+				Status:  http.StatusBadRequest,
+				Message: fmt.Sprintf("Query parameter %q is not properly escaped", key),
+			}
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// urlParam returns the unescaped URL parameter with the given key. chi routes
+// on the escaped path only if it differs from the default encoding of the
+// path (r.URL.RawPath is set), otherwise the parameter is unescaped already.
+func urlParam(r *http.Request, key string) (string, *Error) {
+	val := chi.URLParam(r, key)
+	if r.URL.RawPath == "" {
+		return val, nil
+	}
+	unescapedVal, err := url.PathUnescape(val)
+	if err != nil {
+		return "", &Error{
+			Err:     fmt.Errorf("failed to unescape URL parameter %q: %w", key, err),
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("URL parameter %q is not properly escaped", key),
+		}
+	}
+	return unescapedVal, nil
+}
+
+// invalidParamError returns the error that is sent if a parameter value could
+// not be parsed. The parse error's message is sent to the client.
+func invalidParamError(kind string, key string, err error) *Error {
+	return &Error{
+		Err:     fmt.Errorf("failed to parse %s parameter %q: %w", kind, key, err),
+		Status:  http.StatusBadRequest,
+		Message: fmt.Sprintf("Invalid value for %s parameter %q: %v", kind, key, err),
+	}
+}
+
+// missingParamError returns the error that is sent if a required parameter is
+// missing.
+func missingParamError(kind string, key string) *Error {
+	return &Error{
+		Err:     fmt.Errorf("required %s parameter %q is missing", kind, key),
+		Status:  http.StatusBadRequest,
+		Message: fmt.Sprintf("%s parameter %q is required", strings.ToUpper(kind[:1])+kind[1:], key),
+	}
+}
+
+// The errors of the parse functions are sent to the client.
+var (
+	errNotInteger         = errors.New("must be an integer")
+	errNotUnsignedInteger = errors.New("must be a non-negative integer")
+	errNotBoolean         = errors.New("must be a boolean")
+	errNotNumber          = errors.New("must be a number")
+	errNotDuration        = errors.New("must be a duration (e.g. 1m30s)")
+	errNotTimestamp       = errors.New("must be a RFC3339 timestamp (e.g. 2006-01-02T15:04:05Z)")
+)
+
+// paramTypeError returns the error of the parse function of the type, so that
+// BindParams rejects values with the same message as the typed accessors.
+func paramTypeError(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer || (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8) {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		return errNotDuration
+	case t == timeType:
+		return errNotTimestamp
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return errNotInteger
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return errNotUnsignedInteger
+	case reflect.Bool:
+		return errNotBoolean
+	case reflect.Float32, reflect.Float64:
+		return errNotNumber
+	}
+	return fmt.Errorf("must be of type %v", t)
+}
+
+func parseInt(val string) (int, error) {
+	i, err := strconv.ParseInt(val, 10, 0)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return int(i), nil
+}
+
+func parseUint(val string) (uint, error) {
+	u, err := strconv.ParseUint(val, 10, 0)
+	if err != nil {
+		return 0, errNotUnsignedInteger
+	}
+	return uint(u), nil
+}
+
+func parseBool(val string) (bool, error) {
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, errNotBoolean
+	}
+	return b, nil
+}
+
+func parseFloat(val string) (float64, error) {
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, errNotNumber
+	}
+	return f, nil
+}
+
+func parseDuration(val string) (time.Duration, error) {
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, errNotDuration
+	}
+	return d, nil
+}
+
+func parseTime(val string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, errNotTimestamp
+	}
+	return t, nil
+}
+
+// parseUUID validates that val is a UUID in its canonical textual form and
+// returns it lower cased.
+func parseUUID(val string) (string, error) {
+	errInvalid := errors.New("must be a UUID")
+	if len(val) != 36 {
+		return "", errInvalid
+	}
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return "", errInvalid
+			}
+		default:
+			isHex := (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+			if !isHex {
+				return "", errInvalid
+			}
+		}
+	}
+	return strings.ToLower(val), nil
+}
+
+func enumParser(allowed []string) func(string) (string, error) {
+	return func(val string) (string, error) {
+		for _, a := range allowed {
+			if val == a {
+				return val, nil
+			}
+		}
+		return "", fmt.Errorf("must be one of [%s]", strings.Join(allowed, ", "))
+	}
+}
+
+// BindParams binds query and URL parameters to the fields of the struct that
+// dst points to. Fields are bound if they are tagged with `query:"name"` or
+// `path:"name"` (the name of the chi URL parameter). The tags support the
+// following options:
+//
+//   - required: a 400 error is returned if the parameter is missing
+//   - default=<value>: the value that is used if the parameter is missing.
+//     Slices take several default values separated by pipes, e.g.
+//     `query:"partition,default=0|1"`
+//   - enum=<a>|<b>|<c>: the allowed values
+//
+// Supported field types are strings, bools, ints, uints, floats, time.Duration,
+// time.Time (RFC3339) and types implementing encoding.TextUnmarshaler (e.g. a
+// UUID type), as well as pointers and slices of these. Slices receive the
+// values of repeated query parameters and comma separated values. Because the
+// options are comma separated, default values must not contain commas.
+//
+//	type listMessagesParams struct {
+//		TopicName string        `path:"topicName"`
+//		Limit     int           `query:"limit,default=50"`
+//		Order     string        `query:"order,default=asc,enum=asc|desc"`
+//		Since     time.Time     `query:"since,required"`
+//		Partition []int32       `query:"partition"`
+//	}
+func BindParams(r *http.Request, dst interface{}) *Error {
+	bindTagged := func(kind string, tagKey string, lookup func(key string) ([]string, *Error)) error {
+		return walkFields(dst, tagKey, true, func(name string, opts string, field reflect.Value) error {
+			options := parseParamTagOptions(opts)
+
+			values, lookupErr := lookup(name)
+			if lookupErr != nil {
+				return WrapError(lookupErr)
+			}
+			if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+				values = splitCommaValues(values)
+			}
+			if len(values) == 0 {
+				if options.required {
+					return WrapError(missingParamError(kind, name))
+				}
+				if !options.hasDefault {
+					return nil
+				}
+				values = []string{options.defaultValue}
+				if field.Kind() == reflect.Slice {
+					values = strings.Split(options.defaultValue, "|")
+				}
+			}
+
+			if len(options.enum) > 0 {
+				for _, val := range values {
+					if _, err := enumParser(options.enum)(val); err != nil {
+						return WrapError(invalidParamError(kind, name, err))
+					}
+				}
+			}
+
+			if err := setFieldValue(field, values); err != nil {
+				return WrapError(invalidParamError(kind, name, paramTypeError(field.Type())))
+			}
+			return nil
+		})
+	}
+
+	pathLookup := func(key string) ([]string, *Error) {
+		val, restErr := urlParam(r, key)
+		if restErr != nil || val == "" {
+			return nil, restErr
+		}
+		return []string{val}, nil
+	}
+	queryLookup := func(key string) ([]string, *Error) {
+		values, restErr := queryValues(r, key)
+		if restErr != nil {
+			return nil, restErr
+		}
+		nonEmpty := values[:0]
+		for _, val := range values {
+			if val != "" {
+				nonEmpty = append(nonEmpty, val)
+			}
+		}
+		return nonEmpty, nil
+	}
+
+	for _, binding := range []struct {
+		kind   string
+		tagKey string
+		lookup func(key string) ([]string, *Error)
+	}{
+		{"URL", "path", pathLookup},
+		{"query", "query", queryLookup},
+	} {
+		if err := bindTagged(binding.kind, binding.tagKey, binding.lookup); err != nil {
+			if restErr, ok := unwrapError(err); ok {
+				return restErr
+			}
+			return &Error{Err: err, Status: http.StatusInternalServerError, Message: "Internal Server Error"}
+		}
+	}
+
+	return nil
+}
+
+type paramTagOptions struct {
+	required     bool
+	hasDefault   bool
+	defaultValue string
+	enum         []string
+}
+
+func parseParamTagOptions(opts string) paramTagOptions {
+	var options paramTagOptions
+	if opts == "" {
+		return options
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch strings.TrimSpace(key) {
+		case "required":
+			options.required = true
+		case "default":
+			options.hasDefault = true
+			options.defaultValue = value
+		case "enum":
+			options.enum = strings.Split(value, "|")
+		}
+	}
+	return options
+}
+
+// splitCommaValues splits comma separated values and drops empty values.
+func splitCommaValues(values []string) []string {
+	var result []string
+	for _, val := range values {
+		for _, item := range strings.Split(val, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
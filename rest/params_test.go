@@ -0,0 +1,198 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedQueryParams(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?limit=25&offset=abc&verbose=true&since=2023-01-02T15:04:05Z&order=desc&topic=a,b&topic=c&broken=%zz", nil)
+
+	limit, restErr := GetQueryParamInt(req, "limit", 50)
+	require.Nil(t, restErr)
+	assert.Equal(t, 25, limit)
+
+	pageSize, restErr := GetQueryParamInt(req, "pageSize", 50)
+	require.Nil(t, restErr)
+	assert.Equal(t, 50, pageSize)
+
+	_, restErr = GetQueryParamUint(req, "offset", 0)
+	require.NotNil(t, restErr)
+	assert.Equal(t, http.StatusBadRequest, restErr.Status)
+	assert.Equal(t, `Invalid value for query parameter "offset": must be a non-negative integer`, restErr.Message)
+
+	verbose, restErr := GetQueryParamBool(req, "verbose", false)
+	require.Nil(t, restErr)
+	assert.True(t, verbose)
+
+	since, restErr := GetQueryParamTime(req, "since", time.Time{})
+	require.Nil(t, restErr)
+	assert.Equal(t, time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), since)
+
+	order, restErr := GetQueryParamEnum(req, "order", "asc", "asc", "desc")
+	require.Nil(t, restErr)
+	assert.Equal(t, "desc", order)
+
+	_, restErr = GetQueryParamEnum(req, "limit", "asc", "asc", "desc")
+	require.NotNil(t, restErr)
+
+	topics, restErr := GetQueryParamList(req, "topic")
+	require.Nil(t, restErr)
+	assert.Equal(t, []string{"a", "b", "c"}, topics)
+
+	_, restErr = GetQueryParamValues(req, "broken")
+	require.NotNil(t, restErr)
+	assert.Equal(t, `Query parameter "broken" is not properly escaped`, restErr.Message)
+}
+
+func TestTypedURLParams(t *testing.T) {
+	router := chi.NewRouter()
+
+	var id string
+	var partition int
+	var restErr *Error
+	router.Get("/topics/{topicId}/partitions/{partitionId}", func(w http.ResponseWriter, r *http.Request) {
+		id, restErr = GetURLParamUUID(r, "topicId")
+		if restErr != nil {
+			return
+		}
+		partition, restErr = GetURLParamInt(r, "partitionId")
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/topics/6BA7B810-9DAD-11D1-80B4-00C04FD430C8/partitions/3", nil))
+	require.Nil(t, restErr)
+	assert.Equal(t, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", id)
+	assert.Equal(t, 3, partition)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/topics/orders/partitions/3", nil))
+	require.NotNil(t, restErr)
+	assert.Equal(t, `Invalid value for URL parameter "topicId": must be a UUID`, restErr.Message)
+}
+
+func TestTypedURLParamsScalars(t *testing.T) {
+	router := chi.NewRouter()
+
+	var (
+		compacted bool
+		ratio     float64
+		retention time.Duration
+		since     time.Time
+		restErr   *Error
+	)
+	router.Get("/{compacted}/{ratio}/{retention}/{since}", func(w http.ResponseWriter, r *http.Request) {
+		if compacted, restErr = GetURLParamBool(r, "compacted"); restErr != nil {
+			return
+		}
+		if ratio, restErr = GetURLParamFloat(r, "ratio"); restErr != nil {
+			return
+		}
+		if retention, restErr = GetURLParamDuration(r, "retention"); restErr != nil {
+			return
+		}
+		since, restErr = GetURLParamTime(r, "since")
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/true/0.5/1h30m/2023-01-02T15:04:05Z", nil))
+	require.Nil(t, restErr)
+	assert.True(t, compacted)
+	assert.Equal(t, 0.5, ratio)
+	assert.Equal(t, 90*time.Minute, retention)
+	assert.Equal(t, time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), since)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/true/0.5/forever/2023-01-02T15:04:05Z", nil))
+	require.NotNil(t, restErr)
+	assert.Equal(t, `Invalid value for URL parameter "retention": must be a duration (e.g. 1m30s)`, restErr.Message)
+}
+
+func TestURLParamUnescaping(t *testing.T) {
+	router := chi.NewRouter()
+
+	var name string
+	var restErr *Error
+	router.Get("/topics/{topicName}", func(w http.ResponseWriter, r *http.Request) {
+		name, restErr = ParseURLParam(r, "topicName", func(s string) (string, error) { return s, nil })
+	})
+
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"/topics/orders", "orders"},
+		{"/topics/a%25b", "a%b"},
+		{"/topics/100%25%20done", "100% done"},
+		{"/topics/orders%2Fv1", "orders/v1"},
+		{"/topics/a%25%2Fb", "a%/b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+			require.Nil(t, restErr)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+}
+
+func TestBindParams(t *testing.T) {
+	type listMessagesParams struct {
+		TopicName  string        `path:"topicName"`
+		Limit      int           `query:"limit,default=50"`
+		Order      string        `query:"order,default=asc,enum=asc|desc"`
+		Timeout    time.Duration `query:"timeout"`
+		Partitions []int32       `query:"partition"`
+		Fields     []string      `query:"field,default=key|value"`
+		Since      *time.Time    `query:"since,required"`
+		Ignored    string
+	}
+
+	newRequest := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/topics/orders%2Fv1"+strings.TrimPrefix(target, "/"), nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("topicName", "orders%2Fv1")
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		var params listMessagesParams
+		restErr := BindParams(newRequest("/?partition=1,2&partition=3&timeout=5s&since=2023-01-02T15:04:05Z&Ignored=x"), &params)
+		require.Nil(t, restErr)
+
+		since := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+		assert.Equal(t, listMessagesParams{
+			TopicName:  "orders/v1",
+			Limit:      50,
+			Order:      "asc",
+			Timeout:    5 * time.Second,
+			Partitions: []int32{1, 2, 3},
+			Fields:     []string{"key", "value"},
+			Since:      &since,
+		}, params)
+	})
+
+	tests := []struct {
+		target          string
+		expectedMessage string
+	}{
+		{"/", `Query parameter "since" is required`},
+		{"/?since=yesterday", `Invalid value for query parameter "since": must be a RFC3339 timestamp (e.g. 2006-01-02T15:04:05Z)`},
+		{"/?since=2023-01-02T15:04:05Z&limit=ten", `Invalid value for query parameter "limit": must be an integer`},
+		{"/?since=2023-01-02T15:04:05Z&partition=first", `Invalid value for query parameter "partition": must be an integer`},
+		{"/?since=2023-01-02T15:04:05Z&order=random", `Invalid value for query parameter "order": must be one of [asc, desc]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			var params listMessagesParams
+			restErr := BindParams(newRequest(tt.target), &params)
+			require.NotNil(t, restErr)
+			assert.Equal(t, http.StatusBadRequest, restErr.Status)
+			assert.Equal(t, tt.expectedMessage, restErr.Message)
+		})
+	}
+}
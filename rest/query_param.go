@@ -12,6 +12,7 @@ package rest
 import (
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // GetQueryParam retrieves the query parameter with your given key from the request,
@@ -30,3 +31,84 @@ func GetQueryParam(r *http.Request, key string) string {
 	}
 	return unescapedVal
 }
+
+// ParseQueryParam parses the query parameter with the given key using parse.
+// If the parameter does not exist or is empty the default value is returned.
+// If the parameter is not properly escaped or cannot be parsed a 400 REST error
+// is returned that names the parameter and contains the message of the parse
+// error.
+func ParseQueryParam[T any](r *http.Request, key string, defaultValue T, parse func(string) (T, error)) (T, *Error) {
+	values, restErr := queryValues(r, key)
+	if restErr != nil {
+		return defaultValue, restErr
+	}
+	if len(values) == 0 || values[0] == "" {
+		return defaultValue, nil
+	}
+
+	val, err := parse(values[0])
+	if err != nil {
+		return defaultValue, invalidParamError("query", key, err)
+	}
+	return val, nil
+}
+
+// GetQueryParamInt parses the query parameter as integer.
+func GetQueryParamInt(r *http.Request, key string, defaultValue int) (int, *Error) {
+	return ParseQueryParam(r, key, defaultValue, parseInt)
+}
+
+// GetQueryParamUint parses the query parameter as non-negative integer.
+func GetQueryParamUint(r *http.Request, key string, defaultValue uint) (uint, *Error) {
+	return ParseQueryParam(r, key, defaultValue, parseUint)
+}
+
+// GetQueryParamBool parses the query parameter as boolean. Accepted values are
+// the ones accepted by strconv.ParseBool.
+func GetQueryParamBool(r *http.Request, key string, defaultValue bool) (bool, *Error) {
+	return ParseQueryParam(r, key, defaultValue, parseBool)
+}
+
+// GetQueryParamFloat parses the query parameter as floating point number.
+func GetQueryParamFloat(r *http.Request, key string, defaultValue float64) (float64, *Error) {
+	return ParseQueryParam(r, key, defaultValue, parseFloat)
+}
+
+// GetQueryParamDuration parses the query parameter as duration, e.g. "1m30s".
+func GetQueryParamDuration(r *http.Request, key string, defaultValue time.Duration) (time.Duration, *Error) {
+	return ParseQueryParam(r, key, defaultValue, parseDuration)
+}
+
+// GetQueryParamTime parses the query parameter as RFC3339 timestamp.
+func GetQueryParamTime(r *http.Request, key string, defaultValue time.Time) (time.Time, *Error) {
+	return ParseQueryParam(r, key, defaultValue, parseTime)
+}
+
+// GetQueryParamUUID validates that the query parameter is a UUID and returns it
+// in its lower cased canonical form.
+func GetQueryParamUUID(r *http.Request, key string, defaultValue string) (string, *Error) {
+	return ParseQueryParam(r, key, defaultValue, parseUUID)
+}
+
+// GetQueryParamEnum validates that the query parameter is one of the allowed
+// values.
+func GetQueryParamEnum(r *http.Request, key string, defaultValue string, allowed ...string) (string, *Error) {
+	return ParseQueryParam(r, key, defaultValue, enumParser(allowed))
+}
+
+// GetQueryParamValues returns all values of a query parameter that may be
+// repeated, e.g. "?topic=a&topic=b".
+func GetQueryParamValues(r *http.Request, key string) ([]string, *Error) {
+	return queryValues(r, key)
+}
+
+// GetQueryParamList returns all values of a query parameter which may be
+// repeated and may contain comma separated values, e.g. "?topic=a,b&topic=c".
+// Empty values are dropped.
+func GetQueryParamList(r *http.Request, key string) ([]string, *Error) {
+	values, restErr := queryValues(r, key)
+	if restErr != nil {
+		return nil, restErr
+	}
+	return splitCommaValues(values), nil
+}
@@ -5,42 +5,88 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
-// Server struct to handle a common http routing server
-type redirectServer struct {
-	cfg *Config
-
-	Server *http.Server
-	Logger *slog.Logger
-}
-
 // newRedirectServer creates a new server whose sole purpose it is to
-// redirect HTTP requests to their equivalent HTTPS version.
-func newRedirectServer(cfg *Config, logger *slog.Logger) *Server {
+// redirect HTTP requests to their equivalent HTTPS version. Requests to
+// exempt paths (e.g. ACME HTTP-01 challenges and the health probes) are
+// passed to next instead.
+func newRedirectServer(cfg *Config, logger *slog.Logger, health *HealthRegistry, next http.Handler) *Server {
 	copiedCfg := *cfg
 	copiedCfg.TLS.Enabled = false
+	copiedCfg.AdminListenPort = 0
+	copiedCfg.HTTPListenUnixSocket = ""
+	copiedCfg.SystemdSocketActivation = false
+	copiedCfg.GracefulRestart = false
+	if cfg.Redirect.ListenAddress != "" {
+		copiedCfg.HTTPListenAddress = cfg.Redirect.ListenAddress
+	}
 
 	redirectPort := cfg.HTTPSListenPort
 	if cfg.AdvertisedHTTPSListenPort != 0 {
 		redirectPort = cfg.AdvertisedHTTPSListenPort
 	}
+	statusCode := cfg.Redirect.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusMovedPermanently
+	}
+	exemptPaths := cfg.Redirect.ExemptPaths
+	if cfg.HealthPathPrefix != "" {
+		exemptPaths = append(exemptPaths[:len(exemptPaths):len(exemptPaths)], "/"+strings.Trim(cfg.HealthPathPrefix, "/")+"/")
+	}
+
 	return &Server{
-		cfg: &copiedCfg,
+		name: "redirect",
+		cfg:  &copiedCfg,
 		Server: &http.Server{
-			ReadTimeout:  cfg.HTTPServerReadTimeout,
-			WriteTimeout: cfg.HTTPServerWriteTimeout,
-			IdleTimeout:  cfg.HTTPServerIdleTimeout,
-			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			ReadTimeout:       cfg.HTTPServerReadTimeout,
+			ReadHeaderTimeout: cfg.HTTPServerReadHeaderTimeout,
+			MaxHeaderBytes:    cfg.HTTPServerMaxHeaderBytes,
+			WriteTimeout:      cfg.HTTPServerWriteTimeout,
+			IdleTimeout:       cfg.HTTPServerIdleTimeout,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
 			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				host, _, _ := net.SplitHostPort(r.Host)
-				u := r.URL
-				u.Host = net.JoinHostPort(host, strconv.Itoa(redirectPort))
+				if next != nil && MatchPath(exemptPaths, r.URL.Path) {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				host, _, err := net.SplitHostPort(r.Host)
+				if err != nil {
+					// The Host header does not contain a port
+					host = r.Host
+				}
+				u := *r.URL
+				u.Host = host
+				if !cfg.Redirect.OmitDefaultPort || redirectPort != 443 {
+					u.Host = net.JoinHostPort(host, strconv.Itoa(redirectPort))
+				}
 				u.Scheme = "https"
 				w.Header().Set("Connection", "close")
-				http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+				http.Redirect(w, r, u.String(), statusCode)
 			}),
 		},
 		Logger: logger,
+		Health: health,
+	}
+}
+
+// newHSTSHandler adds the Strict-Transport-Security header to all responses
+// that are served over TLS.
+func newHSTSHandler(cfg HSTSConfig, next http.Handler) http.Handler {
+	value := "max-age=" + strconv.FormatInt(int64(cfg.MaxAge.Seconds()), 10)
+	if cfg.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if cfg.Preload {
+		value += "; preload"
 	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+		next.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectServer(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("served"))
+	})
+
+	tests := []struct {
+		name         string
+		configure    func(cfg *Config)
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name:         "redirects to https listen port",
+			target:       "http://example.com:8080/topics?page=2",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://example.com:8081/topics?page=2",
+		},
+		{
+			name: "omits default port",
+			configure: func(cfg *Config) {
+				cfg.AdvertisedHTTPSListenPort = 443
+			},
+			target:       "http://example.com:8080/topics",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://example.com/topics",
+		},
+		{
+			name: "keeps default port if configured",
+			configure: func(cfg *Config) {
+				cfg.AdvertisedHTTPSListenPort = 443
+				cfg.Redirect.OmitDefaultPort = false
+			},
+			target:       "http://example.com/topics",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://example.com:443/topics",
+		},
+		{
+			name: "permanent redirect",
+			configure: func(cfg *Config) {
+				cfg.Redirect.StatusCode = http.StatusPermanentRedirect
+			},
+			target:       "http://example.com/topics",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://example.com:8081/topics",
+		},
+		{
+			name:       "acme challenge is exempt",
+			target:     "http://example.com/.well-known/acme-challenge/token",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "health probes are exempt",
+			configure: func(cfg *Config) {
+				cfg.HealthPathPrefix = "/health"
+			},
+			target:     "http://example.com/health/ready",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "custom exempt path",
+			configure: func(cfg *Config) {
+				cfg.Redirect.ExemptPaths = []string{"/status"}
+			},
+			target:       "http://example.com/status/sub",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://example.com:8081/status/sub",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			cfg.SetDefaults()
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+			srv := newRedirectServer(&cfg, slog.Default(), NewHealthRegistry(), next)
+
+			rec := httptest.NewRecorder()
+			srv.Server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirectServerListenAddress(t *testing.T) {
+	var cfg Config
+	cfg.SetDefaults()
+	cfg.HTTPListenAddress = "0.0.0.0"
+	cfg.Redirect.ListenAddress = "127.0.0.1"
+
+	srv := newRedirectServer(&cfg, slog.Default(), NewHealthRegistry(), nil)
+	assert.Equal(t, "127.0.0.1", srv.cfg.HTTPListenAddress)
+	assert.Equal(t, cfg.HTTPListenPort, srv.cfg.HTTPListenPort)
+}
+
+func TestHSTSHandler(t *testing.T) {
+	handler := newHSTSHandler(HSTSConfig{MaxAge: 365 * 24 * time.Hour, IncludeSubdomains: true}, http.NotFoundHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+}
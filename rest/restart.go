@@ -0,0 +1,240 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// inheritedListenersEnv passes the listeners to the restarted process as
+	// comma separated name:fd pairs, e.g. "http:3,admin:4".
+	inheritedListenersEnv = "REST_SERVER_INHERITED_LISTENERS"
+	// readyFDEnv is the file descriptor of the pipe that the restarted process
+	// writes to as soon as it is ready.
+	readyFDEnv = "REST_SERVER_READY_FD"
+)
+
+// errRestarted is the cause of the context cancellation after the server has
+// handed over its listeners to the restarted process.
+var errRestarted = errors.New("server has been restarted")
+
+// inheritedListeners are the listeners and the readiness pipe that have been
+// passed by the parent process during a graceful restart.
+type inheritedListeners struct {
+	listeners map[string]net.Listener
+	readyFile *os.File
+}
+
+var inherited struct {
+	once      sync.Once
+	listeners map[string]net.Listener
+	err       error
+	// readyFile is taken by the first server that reports readiness
+	readyFile atomic.Pointer[os.File]
+}
+
+// inheritedListener returns the listener with the given name that has been
+// passed by the parent process, or nil if the process has not been started by
+// a graceful restart.
+func inheritedListener(name string) (net.Listener, error) {
+	inherited.once.Do(func() {
+		var result inheritedListeners
+		result, inherited.err = inheritListeners()
+		inherited.listeners = result.listeners
+		inherited.readyFile.Store(result.readyFile)
+	})
+	if inherited.err != nil {
+		return nil, inherited.err
+	}
+	return inherited.listeners[name], nil
+}
+
+// inheritListeners creates listeners for the file descriptors passed by the
+// parent process. The environment variables are unset, so that they are not
+// inherited by child processes.
+func inheritListeners() (inheritedListeners, error) {
+	var result inheritedListeners
+	listenersValue, readyValue := os.Getenv(inheritedListenersEnv), os.Getenv(readyFDEnv)
+	os.Unsetenv(inheritedListenersEnv)
+	os.Unsetenv(readyFDEnv)
+	if listenersValue == "" {
+		return result, nil
+	}
+
+	result.listeners = make(map[string]net.Listener)
+	for _, pair := range strings.Split(listenersValue, ",") {
+		name, fdValue, _ := strings.Cut(pair, ":")
+		fd, err := strconv.Atoi(fdValue)
+		if err != nil {
+			return result, fmt.Errorf("invalid inherited listener %q: %w", pair, err)
+		}
+		f := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return result, fmt.Errorf("failed to use inherited listener %q: %w", name, err)
+		}
+		result.listeners[name] = listener
+	}
+
+	if readyValue != "" {
+		fd, err := strconv.Atoi(readyValue)
+		if err != nil {
+			return result, fmt.Errorf("invalid readiness file descriptor %q: %w", readyValue, err)
+		}
+		result.readyFile = os.NewFile(uintptr(fd), "ready")
+	}
+	return result, nil
+}
+
+// notifyParentWhenReady tells the parent process that started this process
+// during a graceful restart that it can shut down, as soon as the readiness
+// probe passes.
+func (s *Server) notifyParentWhenReady(ctx context.Context) {
+	readyFile := inherited.readyFile.Swap(nil)
+	if readyFile == nil {
+		return
+	}
+	defer readyFile.Close()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if s.Health.Check(ctx, ProbeReadiness).Status != HealthStatusFail {
+			if _, err := readyFile.Write([]byte{1}); err != nil {
+				s.Logger.Error("failed to notify parent process about readiness", slog.Any("error", err))
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// restart starts a new process of the current executable with the same
+// arguments, hands over the listeners and waits until it reports readiness.
+// The new process is killed if it does not become ready within the timeout.
+//
+// The new process is a child of the current one and is reparented once the
+// current process exits. Under systemd it must be tracked as the new main
+// process (e.g. via PIDFile= or NotifyAccess=all with sd_notify MAINPID=),
+// otherwise it is killed along with the old main process with the default
+// KillMode=control-group.
+func restart(ctx context.Context, logger *slog.Logger, listeners map[string]net.Listener, timeout time.Duration) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to determine executable: %w", err)
+	}
+
+	names := make([]string, 0, len(listeners))
+	for name := range listeners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// ExtraFiles are passed as file descriptors 3, 4, ... to the child
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		fileListener, ok := listeners[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %q does not support handing over its file descriptor", name)
+		}
+		f, err := fileListener.File()
+		if err != nil {
+			return fmt.Errorf("failed to get file descriptor of listener %q: %w", name, err)
+		}
+		pairs = append(pairs, fmt.Sprintf("%s:%d", name, 3+len(files)))
+		files = append(files, f)
+	}
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create readiness pipe: %w", err)
+	}
+	defer readyReader.Close()
+	readyFD := 3 + len(files)
+	files = append(files, readyWriter)
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, inheritedListenersEnv+"=") && !strings.HasPrefix(kv, readyFDEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		inheritedListenersEnv+"="+strings.Join(pairs, ","),
+		readyFDEnv+"="+strconv.Itoa(readyFD))
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+	// Only the child must hold the write end, so that reads fail if it exits
+	readyWriter.Close()
+	files = files[:len(files)-1]
+	logger.Info("Started new process, waiting for it to become ready", slog.Int("pid", cmd.Process.Pid))
+
+	readyCh := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyReader.Read(buf)
+		readyCh <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-readyCh:
+		if err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return fmt.Errorf("new process exited before becoming ready: %w", err)
+		}
+	case <-timer.C:
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("new process did not become ready within %v", timeout)
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return ctx.Err()
+	}
+
+	logger.Info("New process is ready", slog.Int("pid", cmd.Process.Pid))
+	// The new process serves on the same Unix sockets, so closing the
+	// listeners during the shutdown must not remove the socket files
+	for _, listener := range listeners {
+		if unixListener, ok := listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	}
+	// The new process outlives this one
+	_ = cmd.Process.Release()
+	return nil
+}
@@ -0,0 +1,144 @@
+//go:build unix
+
+package rest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restartTestSocketEnv is set to the path of the Unix socket when the test
+// binary is started as restarted process by TestRestartUnixSocket.
+const restartTestSocketEnv = "REST_RESTART_TEST_SOCKET"
+
+func TestMain(m *testing.M) {
+	if path := os.Getenv(restartTestSocketEnv); path != "" {
+		os.Exit(runRestartedTestServer(path))
+	}
+	os.Exit(m.Run())
+}
+
+// runRestartedTestServer serves on the inherited Unix socket until it receives
+// a request to /stop.
+func runRestartedTestServer(path string) int {
+	var cfg Config
+	cfg.SetDefaults()
+	cfg.HTTPListenUnixSocket = path
+	cfg.SystemdSocketActivation = false
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	router := chi.NewRouter()
+	router.Get("/pid", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(strconv.Itoa(os.Getpid()))) })
+	router.Post("/stop", func(w http.ResponseWriter, r *http.Request) { cancel() })
+
+	srv, err := NewServer(&cfg, slog.Default(), router)
+	if err != nil {
+		return 1
+	}
+	if err := srv.Run(ctx); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func TestRestartUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	listener, err := listenUnix(path, "")
+	require.NoError(t, err)
+
+	t.Setenv(restartTestSocketEnv, path)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	require.NoError(t, restart(ctx, slog.Default(), map[string]net.Listener{mainListenerName: listener}, 20*time.Second))
+
+	// The parent shuts down after the restarted process became ready
+	require.NoError(t, listener.Close())
+	_, err = os.Stat(path)
+	require.NoError(t, err, "socket file must be kept for the restarted process")
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+		DisableKeepAlives: true,
+	}}
+	res, err := client.Get("http://unix/pid")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.NotEqual(t, strconv.Itoa(os.Getpid()), string(body))
+
+	res, err = client.Post("http://unix/stop", "", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+}
+
+func TestInheritListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	readyReader, readyWriter, err := os.Pipe()
+	require.NoError(t, err)
+	defer readyReader.Close()
+
+	// The file descriptors are owned by the "restarted process" from now on
+	rawFD := func(f interface{ Fd() uintptr }) int {
+		fd, err := syscall.Dup(int(f.Fd()))
+		require.NoError(t, err)
+		return fd
+	}
+	f, err := l.(*net.TCPListener).File()
+	require.NoError(t, err)
+	listenerFD := rawFD(f)
+	f.Close()
+	readyFD := rawFD(readyWriter)
+	readyWriter.Close()
+
+	t.Setenv(inheritedListenersEnv, "http:"+strconv.Itoa(listenerFD))
+	t.Setenv(readyFDEnv, strconv.Itoa(readyFD))
+
+	result, err := inheritListeners()
+	require.NoError(t, err)
+	assert.Empty(t, os.Getenv(inheritedListenersEnv), "environment must not be inherited by children")
+
+	inheritedListener := result.listeners["http"]
+	require.NotNil(t, inheritedListener)
+	defer inheritedListener.Close()
+	assert.Equal(t, l.Addr().String(), inheritedListener.Addr().String())
+
+	go func() {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := inheritedListener.Accept()
+	require.NoError(t, err)
+	conn.Close()
+
+	// The restarted server reports readiness as soon as its readiness probe passes
+	srv := &Server{Logger: slog.Default(), Health: NewHealthRegistry()}
+	inherited.readyFile.Store(result.readyFile)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.notifyParentWhenReady(ctx)
+
+	b, err := io.ReadAll(readyReader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, b)
+}
@@ -9,40 +9,104 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
-	"sync"
 	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/cloudhut/common/middleware/compress"
 	"github.com/cloudhut/common/tls"
 )
 
 // Server struct to handle a common http routing server
 type Server struct {
-	cfg *Config
+	// name identifies sibling servers, see listenerName
+	name string
+	cfg  *Config
 
 	Router *chi.Mux
 	Server *http.Server
 	Logger *slog.Logger
+	// Listener is a pre-opened listener the server serves on. If it is nil,
+	// the listener is created according to the config.
+	Listener net.Listener
+	// Health is the registry for the health checks that are served as
+	// liveness, readiness and startup probes.
+	Health *HealthRegistry
+	// Compressor compresses the responses of the router. It is nil if the
+	// compression level is 0. Register further encodings (e.g. "zstd" or "br")
+	// with SetEncoder before the server is started, e.g. with
+	// github.com/klauspost/compress/zstd:
+	//
+	//	srv.Compressor.SetEncoder("zstd", func(w io.Writer, level int) (compress.Encoder, error) {
+	//		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	//	})
+	Compressor *compress.Compressor
+	// MetricsGatherer provides the metrics that the admin server serves. It
+	// defaults to prometheus.DefaultGatherer. Set it to the registry that has
+	// been passed as Registerer to the middlewares if it is not the default.
+	MetricsGatherer prometheus.Gatherer
 }
 
-// NewServer create server instance
+// NewServer create server instance. The router is mounted under the configured
+// base path, see BasePathFromContext. Responses are compressed according to the
+// configured compression level, see Server.Compressor. The health probes are served under the
+// configured health path prefix, independent of the router.
 func NewServer(cfg *Config, logger *slog.Logger, router *chi.Mux) (*Server, error) {
+	health := NewHealthRegistry()
+
+	var handler http.Handler = router
+	var compressor *compress.Compressor
+	if cfg.CompressionLevel > 0 {
+		compressor = compress.NewCompressor(cfg.CompressionLevel)
+		handler = compressor.Wrap(handler)
+	}
+	if cfg.HealthPathPrefix != "" {
+		handler = newHealthHandler(cfg.HealthPathPrefix, health, handler)
+	}
+	handler = newBasePathHandler(cfg, handler)
+	if cfg.TLS.Enabled && cfg.TLS.HSTS.MaxAge > 0 {
+		handler = newHSTSHandler(cfg.TLS.HSTS, handler)
+	}
+
 	server := &Server{
 		cfg:    cfg,
 		Router: router,
 		Server: &http.Server{
-			ReadTimeout:  cfg.HTTPServerReadTimeout,
-			WriteTimeout: cfg.HTTPServerWriteTimeout,
-			IdleTimeout:  cfg.HTTPServerIdleTimeout,
-			Handler:      router,
-			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			ReadTimeout:       cfg.HTTPServerReadTimeout,
+			ReadHeaderTimeout: cfg.HTTPServerReadHeaderTimeout,
+			WriteTimeout:      cfg.HTTPServerWriteTimeout,
+			IdleTimeout:       cfg.HTTPServerIdleTimeout,
+			MaxHeaderBytes:    cfg.HTTPServerMaxHeaderBytes,
+			Handler:           handler,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			HTTP2: &http.HTTP2Config{
+				MaxConcurrentStreams: cfg.HTTP2.MaxConcurrentStreams,
+			},
 		},
-		Logger: logger,
+		Logger:          logger,
+		Health:          health,
+		Compressor:      compressor,
+		MetricsGatherer: prometheus.DefaultGatherer,
+	}
+
+	if cfg.HTTP2.H2CEnabled {
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		server.Server.Protocols = &protocols
 	}
 
 	if cfg.TLS.Enabled {
+		switch cfg.Redirect.StatusCode {
+		case 0, http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		default:
+			return nil, fmt.Errorf("redirect status code must be %d or %d, but is %d",
+				http.StatusMovedPermanently, http.StatusPermanentRedirect, cfg.Redirect.StatusCode)
+		}
+
 		tlsCfg, err := tls.BuildWatchedTLSConfig(logger, cfg.TLS.CertFilepath, cfg.TLS.KeyFilepath, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create TLS config: %w", err)
@@ -53,60 +117,158 @@ func NewServer(cfg *Config, logger *slog.Logger, router *chi.Mux) (*Server, erro
 	return server, nil
 }
 
-// Start the HTTP server and blocks until we either receive a signal or the HTTP server returns an error.
+// Start the HTTP server and blocks until we either receive a SIGINT or SIGTERM signal
+// or the HTTP server returns an error. Use Run to control the lifecycle yourself.
 func (s *Server) Start() error {
-	var wg sync.WaitGroup
-	wg.Add(1)
+	ctx, stop := NotifyShutdownSignals(context.Background())
+	defer stop()
 
-	// Listen for signals - shutdown the server if we receive one
-	go func() {
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
+	return s.Run(ctx)
+}
+
+// NotifyShutdownSignals returns a copy of ctx that is cancelled as soon as the
+// process receives a SIGINT or SIGTERM signal. Call stop to release the signal
+// handler. Pass the returned context to Run to shut down the server on signals.
+func NotifyShutdownSignals(ctx context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ServerGracefulShutdownTimeout)
-		defer cancel()
+// Run starts the HTTP server (plus the HTTP to HTTPS redirect server if TLS is
+// enabled and the admin server if an admin port is configured) and serves until
+// ctx is cancelled. Afterwards the servers are shut down gracefully within the
+// ServerGracefulShutdownTimeout. Run returns an error if a listener could not
+// be opened, a server failed or the graceful shutdown did not complete in
+// time. It returns nil after a successful shutdown.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+	s.Logger.Info("Server listening on address", listenerAttrs(listener)...)
 
-		s.Logger.Info("Stopping HTTP server", slog.String("reason", "received signal"))
-		s.Server.SetKeepAlivesEnabled(false)
-		err := s.Server.Shutdown(ctx)
+	// Sibling listeners are opened upfront, so that they can be handed over
+	// during graceful restarts as well
+	type sibling struct {
+		description string
+		server      *Server
+	}
+	var siblings []sibling
+	if s.cfg.TLS.Enabled {
+		siblings = append(siblings, sibling{"HTTP to HTTPS redirect server", newRedirectServer(s.cfg, s.Logger, s.Health, s.Server.Handler)})
+	}
+	if s.cfg.AdminListenPort != 0 {
+		siblings = append(siblings, sibling{"admin server", newAdminServer(s.cfg, s.Logger, s.Health, s.MetricsGatherer)})
+	}
+	listeners := map[string]net.Listener{s.listenerName(): listener}
+	for _, sib := range siblings {
+		siblingListener, err := sib.server.listen()
 		if err != nil {
-			s.Logger.Error("Failed to shutdown server gracefully", slog.Any("error", err))
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("%s failed: %w", sib.description, err)
 		}
+		sib.server.Listener = siblingListener
+		listeners[sib.server.listenerName()] = siblingListener
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
-		wg.Done()
+	serveErrCh := make(chan error, 1)
+	go func() {
+		var err error
+		if s.cfg.TLS.Enabled {
+			err = s.Server.ServeTLS(listener, "", "")
+		} else {
+			err = s.Server.Serve(listener)
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		serveErrCh <- err
 	}()
 
-	// Serve HTTP server
-	listenerPort := s.cfg.HTTPListenPort
-	if s.cfg.TLS.Enabled {
-		listenerPort = s.cfg.HTTPSListenPort
-	}
-	listener, err := net.Listen("tcp", net.JoinHostPort(s.cfg.HTTPListenAddress, strconv.Itoa(listenerPort)))
-	if err != nil {
-		return err
+	// Sibling servers are stopped via ctx, so that they shut down alongside
+	siblingErrCh := make(chan error, len(siblings))
+	for _, sib := range siblings {
+		go func() {
+			err := sib.server.Run(ctx)
+			if err != nil {
+				err = fmt.Errorf("%s failed: %w", sib.description, err)
+			}
+			siblingErrCh <- err
+		}()
 	}
-	s.Logger.Info("Server listening on address", slog.String("address", listener.Addr().String()), slog.Int("port", listenerPort))
 
-	if s.cfg.TLS.Enabled {
-		rdSrv := newRedirectServer(s.cfg, s.Logger)
+	if s.name == "" {
+		go s.notifyParentWhenReady(ctx)
+	}
+	if s.cfg.GracefulRestart {
+		restartCh := make(chan os.Signal, 1)
+		signal.Notify(restartCh, syscall.SIGHUP)
+		defer signal.Stop(restartCh)
 		go func() {
-			err := rdSrv.Start()
-			if err != nil {
-				s.Logger.Error("failed to start HTTP to HTTPS redirect server", slog.Any("error", err))
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-restartCh:
+					s.Logger.Info("Restarting HTTP server", slog.String("reason", "received signal"))
+					if err := restart(ctx, s.Logger, listeners, s.cfg.GracefulRestartTimeout); err != nil {
+						s.Logger.Error("Failed to restart HTTP server, continuing to serve", slog.Any("error", err))
+						continue
+					}
+					cancel(errRestarted)
+					return
+				}
 			}
 		}()
+	}
+
+	var runErr error
+	serveDone, siblingsDone := false, 0
+	select {
+	case <-ctx.Done():
+		reason := "context cancelled"
+		if errors.Is(context.Cause(ctx), errRestarted) {
+			reason = "restarted"
+		}
+		s.Logger.Info("Stopping HTTP server", slog.String("reason", reason), slog.Any("cause", context.Cause(ctx)))
+	case runErr = <-serveErrCh:
+		serveDone = true
+	case runErr = <-siblingErrCh:
+		siblingsDone++
+	}
+	cancel(nil)
 
-		err = s.Server.ServeTLS(listener, "", "")
-	} else {
-		err = s.Server.Serve(listener)
+	shutdownErr := s.shutdown()
+	if !serveDone {
+		runErr = errors.Join(runErr, <-serveErrCh)
 	}
-	if !errors.Is(err, http.ErrServerClosed) {
-		return err
+	for ; siblingsDone < len(siblings); siblingsDone++ {
+		runErr = errors.Join(runErr, <-siblingErrCh)
 	}
+	s.Logger.Info("Stopped HTTP server", listenerAttrs(listener)...)
 
-	wg.Wait()
-	s.Logger.Info("Stopped HTTP server", slog.String("address", listener.Addr().String()), slog.Int("port", listenerPort))
+	return errors.Join(runErr, shutdownErr)
+}
+
+// shutdown fails the readiness probe, waits for the drain delay and then
+// gracefully shuts down the HTTP server within the configured timeout.
+func (s *Server) shutdown() error {
+	s.Health.SetShuttingDown()
+	s.Server.SetKeepAlivesEnabled(false)
+	if s.cfg.ServerShutdownDrainDelay > 0 {
+		s.Logger.Info("Draining traffic before shutting down HTTP server", slog.Duration("delay", s.cfg.ServerShutdownDrainDelay))
+		time.Sleep(s.cfg.ServerShutdownDrainDelay)
+	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ServerGracefulShutdownTimeout)
+	defer cancel()
+
+	if err := s.Server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown server gracefully: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,254 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudhut/common/middleware/compress"
+)
+
+// freePort returns a TCP port that is currently not in use.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func newTestServer(t *testing.T, router *chi.Mux) (*Server, string) {
+	t.Helper()
+	var cfg Config
+	cfg.SetDefaults()
+	cfg.HTTPListenAddress = "127.0.0.1"
+	cfg.HTTPListenPort = freePort(t)
+	cfg.ServerGracefulShutdownTimeout = 5 * time.Second
+
+	srv, err := NewServer(&cfg, slog.Default(), router)
+	require.NoError(t, err)
+	return srv, fmt.Sprintf("http://127.0.0.1:%d", cfg.HTTPListenPort)
+}
+
+func TestServerRun(t *testing.T) {
+	router := chi.NewRouter()
+	router.Get("/ping", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("pong")) })
+	srv, baseURL := newTestServer(t, router)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		res, err := http.Get(baseURL + "/ping")
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after the context has been cancelled")
+	}
+
+	_, err := http.Get(baseURL + "/ping")
+	assert.Error(t, err)
+}
+
+func TestServerRunListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	srv, _ := newTestServer(t, chi.NewRouter())
+	srv.cfg.HTTPListenPort = l.Addr().(*net.TCPAddr).Port
+
+	assert.Error(t, srv.Run(context.Background()))
+}
+
+func TestServerAdminListener(t *testing.T) {
+	srv, _ := newTestServer(t, chi.NewRouter())
+	srv.cfg.AdminListenAddress = "127.0.0.1"
+	srv.cfg.AdminListenPort = freePort(t)
+	adminURL := fmt.Sprintf("http://127.0.0.1:%d", srv.cfg.AdminListenPort)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "admin_test_total", Help: "Test counter."}))
+	srv.MetricsGatherer = registry
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Run(ctx) }()
+
+	// Keep-alives would leave unused connections of the transport open, which
+	// delay the shutdown
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func(path string) int {
+		res, err := client.Get(adminURL + path)
+		if err != nil {
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	require.Eventually(t, func() bool { return get("/health/ready") == http.StatusOK }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, http.StatusOK, get("/metrics"))
+	res, err := client.Get(adminURL + "/metrics")
+	require.NoError(t, err)
+	metrics, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(metrics), "admin_test_total 0")
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/"))
+	assert.Equal(t, http.StatusOK, get("/buildinfo"))
+
+	cancel()
+	assert.NoError(t, <-errCh)
+	assert.Equal(t, 0, get("/metrics"))
+}
+
+func TestServerListeners(t *testing.T) {
+	router := chi.NewRouter()
+	router.Get("/ping", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("pong")) })
+
+	runAndPing := func(t *testing.T, srv *Server, client *http.Client) {
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() { errCh <- srv.Run(ctx) }()
+
+		require.Eventually(t, func() bool {
+			res, err := client.Get("http://server/ping")
+			if err != nil {
+				return false
+			}
+			res.Body.Close()
+			return res.StatusCode == http.StatusOK
+		}, 5*time.Second, 10*time.Millisecond)
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	}
+
+	t.Run("pre-opened listener", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		srv, _ := newTestServer(t, router)
+		srv.Listener = l
+		client := &http.Client{Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "tcp", l.Addr().String())
+			},
+		}}
+		runAndPing(t, srv, client)
+	})
+
+	t.Run("unix socket", func(t *testing.T) {
+		socketPath := filepath.Join(t.TempDir(), "server.sock")
+		srv, _ := newTestServer(t, router)
+		srv.cfg.HTTPListenUnixSocket = socketPath
+		srv.cfg.HTTPListenUnixSocketMode = "0600"
+		client := &http.Client{Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				info, err := os.Stat(socketPath)
+				if err != nil {
+					return nil, err
+				}
+				assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		}}
+		runAndPing(t, srv, client)
+
+		_, err := os.Stat(socketPath)
+		assert.True(t, os.IsNotExist(err), "socket must be removed on shutdown")
+	})
+}
+
+func TestServerH2C(t *testing.T) {
+	router := chi.NewRouter()
+	router.Get("/proto", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.Proto)) })
+	var cfg Config
+	cfg.SetDefaults()
+	cfg.HTTPListenAddress = "127.0.0.1"
+	cfg.HTTPListenPort = freePort(t)
+	cfg.HTTP2.H2CEnabled = true
+	srv, err := NewServer(&cfg, slog.Default(), router)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, srv.Server.ReadHeaderTimeout)
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", cfg.HTTPListenPort)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Run(ctx)
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+	require.Eventually(t, func() bool {
+		res, err := client.Get(baseURL + "/proto")
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+		return res.ProtoMajor == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServerCompressor(t *testing.T) {
+	router := chi.NewRouter()
+	router.Get("/topics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"topics":"` + strings.Repeat("orders,", 512) + `"}`))
+	})
+	srv, _ := newTestServer(t, router)
+	require.NotNil(t, srv.Compressor)
+
+	// Registered encoders take precedence over gzip
+	srv.Compressor.SetEncoder("zstd", func(w io.Writer, level int) (compress.Encoder, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	})
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/topics", nil)
+		req.Header.Set("Accept-Encoding", "gzip, zstd")
+		rec := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rec, req)
+		assert.Equal(t, "zstd", rec.Header().Get("Content-Encoding"))
+
+		decoder, err := zstd.NewReader(rec.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(decoder)
+		decoder.Close()
+		require.NoError(t, err)
+		assert.Equal(t, `{"topics":"`+strings.Repeat("orders,", 512)+`"}`, string(body))
+	}
+
+	var cfg Config
+	cfg.SetDefaults()
+	cfg.CompressionLevel = 0
+	srv, err := NewServer(&cfg, slog.Default(), router)
+	require.NoError(t, err)
+	assert.Nil(t, srv.Compressor)
+}
@@ -0,0 +1,279 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStreamWriteTimeout is the time that each single write of a stream may
+// take. Streams extend the connection's write deadline before every write, so
+// that they are not aborted by the server's WriteTimeout which applies to the
+// whole response.
+const DefaultStreamWriteTimeout = 30 * time.Second
+
+// DefaultSSEHeartbeatInterval is the interval in which heartbeat comments are
+// sent to keep idle Server-Sent Events connections alive.
+const DefaultSSEHeartbeatInterval = 15 * time.Second
+
+// StreamNDJSON streams the items as newline delimited JSON (one JSON document
+// per line) and flushes every item to the client. The stream is aborted if the
+// request context is cancelled.
+//
+// If the iterator returns an error before the first item has been written, a
+// REST error is sent. Errors that occur later can only be logged, because the
+// response status has already been sent.
+func StreamNDJSON[T any](w http.ResponseWriter, r *http.Request, logger *slog.Logger, items iter.Seq2[T, error]) {
+	rc := http.NewResponseController(w)
+	ctx := r.Context()
+
+	started := false
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for item, err := range items {
+		if ctx.Err() != nil {
+			logger.DebugContext(ctx, "aborted NDJSON stream", slog.Any("error", ctx.Err()))
+			return
+		}
+		if err != nil {
+			if !started {
+				serverError(w, r, logger, err)
+				return
+			}
+			logger.ErrorContext(ctx, "failed to stream NDJSON response", slog.String("route", r.RequestURI), slog.Any("error", err))
+			return
+		}
+
+		buf.Reset()
+		if err := enc.Encode(item); err != nil {
+			if !started {
+				serverError(w, r, logger, err)
+				return
+			}
+			logger.ErrorContext(ctx, "failed to encode NDJSON item", slog.String("route", r.RequestURI), slog.Any("error", err))
+			return
+		}
+
+		extendWriteDeadline(rc, DefaultStreamWriteTimeout)
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			logger.DebugContext(ctx, "failed to write NDJSON item", slog.Any("error", err))
+			return
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			logger.DebugContext(ctx, "failed to flush NDJSON item", slog.Any("error", err))
+			return
+		}
+	}
+
+	if !started {
+		// Empty streams still need a content type
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// StreamNDJSONChannel streams all items received from the channel as newline
+// delimited JSON until the channel is closed or the request context is
+// cancelled. See StreamNDJSON.
+func StreamNDJSONChannel[T any](w http.ResponseWriter, r *http.Request, logger *slog.Logger, ch <-chan T) {
+	ctx := r.Context()
+	items := func(yield func(T, error) bool) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-ch:
+				if !ok || !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+	StreamNDJSON(w, r, logger, items)
+}
+
+// SSEEvent is a single Server-Sent Event.
+type SSEEvent struct {
+	// ID is sent as event id. Clients send the last received id in the
+	// Last-Event-ID header when they reconnect.
+	ID string
+	// Event is the event type. Clients default to "message" if it is empty.
+	Event string
+	// Data is the event payload. Strings and byte slices are sent as they are,
+	// all other values are encoded as JSON.
+	Data any
+	// Retry tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// SSEWriter writes Server-Sent Events to a client.
+type SSEWriter struct {
+	// HeartbeatInterval is the interval in which Stream sends heartbeat
+	// comments if no event has been sent. Zero disables heartbeats.
+	HeartbeatInterval time.Duration
+	// WriteTimeout is the time that each single write may take.
+	WriteTimeout time.Duration
+
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	lastEventID string
+}
+
+// NewSSEWriter sends the Server-Sent Events response headers and returns a
+// writer for the events. An error is returned if the response writer does not
+// support flushing.
+func NewSSEWriter(w http.ResponseWriter, r *http.Request) (*SSEWriter, error) {
+	s := &SSEWriter{
+		HeartbeatInterval: DefaultSSEHeartbeatInterval,
+		WriteTimeout:      DefaultStreamWriteTimeout,
+		w:                 w,
+		rc:                http.NewResponseController(w),
+		lastEventID:       r.Header.Get("Last-Event-ID"),
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+
+	extendWriteDeadline(s.rc, s.WriteTimeout)
+	w.WriteHeader(http.StatusOK)
+	if err := s.rc.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to flush event stream: %w", err)
+	}
+
+	return s, nil
+}
+
+// LastEventID returns the value of the Last-Event-ID header that is sent by
+// reconnecting clients. Use it to resume the stream after the last event the
+// client has received.
+func (s *SSEWriter) LastEventID() string {
+	return s.lastEventID
+}
+
+// Send writes and flushes a single event.
+func (s *SSEWriter) Send(event SSEEvent) error {
+	var buf bytes.Buffer
+	if event.ID != "" {
+		writeSSEField(&buf, "id", event.ID)
+	}
+	if event.Event != "" {
+		writeSSEField(&buf, "event", event.Event)
+	}
+	if event.Retry > 0 {
+		writeSSEField(&buf, "retry", strconv.FormatInt(event.Retry.Milliseconds(), 10))
+	}
+
+	var data string
+	switch d := event.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		jsonBytes, err := json.Marshal(d)
+		if err != nil {
+			return fmt.Errorf("failed to encode event data: %w", err)
+		}
+		data = string(jsonBytes)
+	}
+	data = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data)
+	for _, line := range strings.Split(data, "\n") {
+		writeSSEField(&buf, "data", line)
+	}
+	buf.WriteByte('\n')
+
+	return s.write(buf.Bytes())
+}
+
+// Comment writes a comment line which is ignored by clients. Comments are
+// used as heartbeat to keep idle connections from being closed by proxies.
+func (s *SSEWriter) Comment(text string) error {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(": ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	return s.write(buf.Bytes())
+}
+
+// Stream sends all events received from the channel until the channel is
+// closed or the context is cancelled. Heartbeat comments are sent if no event
+// has been sent within the HeartbeatInterval. It returns nil when the channel
+// has been closed, the context's error if it has been cancelled or the write
+// error if the client is gone.
+func (s *SSEWriter) Stream(ctx context.Context, events <-chan SSEEvent) error {
+	var heartbeat <-chan time.Time
+	resetHeartbeat := func() {}
+	if s.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(s.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+		resetHeartbeat = func() { ticker.Reset(s.HeartbeatInterval) }
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-heartbeat:
+			if err := s.Comment("heartbeat"); err != nil {
+				return err
+			}
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.Send(event); err != nil {
+				return err
+			}
+			resetHeartbeat()
+		}
+	}
+}
+
+func (s *SSEWriter) write(p []byte) error {
+	extendWriteDeadline(s.rc, s.WriteTimeout)
+	if _, err := s.w.Write(p); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func writeSSEField(buf *bytes.Buffer, name string, value string) {
+	// Line breaks would terminate the field early
+	value = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(value)
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// extendWriteDeadline moves the connection's write deadline into the future.
+// Response writers that do not support deadlines (e.g. in tests) are ignored.
+func extendWriteDeadline(rc *http.ResponseController, timeout time.Duration) {
+	if timeout <= 0 {
+		_ = rc.SetWriteDeadline(time.Time{})
+		return
+	}
+	_ = rc.SetWriteDeadline(time.Now().Add(timeout))
+}
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamNDJSON(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	type item struct {
+		ID int `json:"id"`
+	}
+
+	t.Run("items", func(t *testing.T) {
+		items := func(yield func(item, error) bool) {
+			for i := 1; i <= 3; i++ {
+				if !yield(item{ID: i}, nil) {
+					return
+				}
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		StreamNDJSON(rec, httptest.NewRequest(http.MethodGet, "/", nil), logger, items)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", rec.Body.String())
+		assert.True(t, rec.Flushed)
+	})
+
+	t.Run("error before first item", func(t *testing.T) {
+		items := func(yield func(item, error) bool) {
+			yield(item{}, errors.New("failed to list items"))
+		}
+
+		rec := httptest.NewRecorder()
+		StreamNDJSON(rec, httptest.NewRequest(http.MethodGet, "/", nil), logger, items)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("channel", func(t *testing.T) {
+		ch := make(chan item, 2)
+		ch <- item{ID: 1}
+		ch <- item{ID: 2}
+		close(ch)
+
+		rec := httptest.NewRecorder()
+		StreamNDJSONChannel(rec, httptest.NewRequest(http.MethodGet, "/", nil), logger, ch)
+
+		assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n", rec.Body.String())
+	})
+}
+
+func TestSSEWriter(t *testing.T) {
+	events := make(chan SSEEvent)
+	streamErr := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Wrap the response writer the same way middleware.Intercept does
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		sse, err := NewSSEWriter(ww, r)
+		if err != nil {
+			streamErr <- err
+			return
+		}
+		sse.HeartbeatInterval = 20 * time.Millisecond
+		require.NoError(t, sse.Send(SSEEvent{Event: "resume", Data: sse.LastEventID()}))
+		streamErr <- sse.Stream(r.Context(), events)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "41")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(res.Body)
+	readMessage := func() []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return lines
+			}
+			lines = append(lines, line[:len(line)-1])
+		}
+	}
+
+	assert.Equal(t, []string{"event: resume", "data: 41"}, readMessage())
+
+	events <- SSEEvent{ID: "42", Event: "progress", Data: map[string]int{"percent": 50}, Retry: 3 * time.Second}
+	assert.Equal(t, []string{"id: 42", "event: progress", "retry: 3000", `data: {"percent":50}`}, readMessage())
+
+	events <- SSEEvent{Data: "line 1\nline 2"}
+	assert.Equal(t, []string{"data: line 1", "data: line 2"}, readMessage())
+
+	// Wait for a heartbeat comment
+	assert.Equal(t, []string{": heartbeat"}, readMessage())
+
+	close(events)
+	select {
+	case err := <-streamErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("stream did not return after the events channel has been closed")
+	}
+}
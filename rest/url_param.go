@@ -12,6 +12,7 @@ package rest
 import (
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -32,3 +33,65 @@ func GetURLParam(r *http.Request, key string) string {
 	}
 	return unescapedVal
 }
+
+// ParseURLParam parses the url parameter with the given key using parse. If the
+// url parameter is empty, not properly escaped or cannot be parsed a 400 REST
+// error is returned that names the parameter.
+func ParseURLParam[T any](r *http.Request, key string, parse func(string) (T, error)) (T, *Error) {
+	var zero T
+	val, restErr := urlParam(r, key)
+	if restErr != nil {
+		return zero, restErr
+	}
+	if val == "" {
+		return zero, missingParamError("URL", key)
+	}
+
+	parsed, err := parse(val)
+	if err != nil {
+		return zero, invalidParamError("URL", key, err)
+	}
+	return parsed, nil
+}
+
+// GetURLParamInt parses the url parameter as integer.
+func GetURLParamInt(r *http.Request, key string) (int, *Error) {
+	return ParseURLParam(r, key, parseInt)
+}
+
+// GetURLParamUint parses the url parameter as non-negative integer.
+func GetURLParamUint(r *http.Request, key string) (uint, *Error) {
+	return ParseURLParam(r, key, parseUint)
+}
+
+// GetURLParamBool parses the url parameter as boolean. Accepted values are the
+// ones accepted by strconv.ParseBool.
+func GetURLParamBool(r *http.Request, key string) (bool, *Error) {
+	return ParseURLParam(r, key, parseBool)
+}
+
+// GetURLParamFloat parses the url parameter as floating point number.
+func GetURLParamFloat(r *http.Request, key string) (float64, *Error) {
+	return ParseURLParam(r, key, parseFloat)
+}
+
+// GetURLParamDuration parses the url parameter as duration, e.g. "1m30s".
+func GetURLParamDuration(r *http.Request, key string) (time.Duration, *Error) {
+	return ParseURLParam(r, key, parseDuration)
+}
+
+// GetURLParamTime parses the url parameter as RFC3339 timestamp.
+func GetURLParamTime(r *http.Request, key string) (time.Time, *Error) {
+	return ParseURLParam(r, key, parseTime)
+}
+
+// GetURLParamUUID validates that the url parameter is a UUID and returns it in
+// its lower cased canonical form.
+func GetURLParamUUID(r *http.Request, key string) (string, *Error) {
+	return ParseURLParam(r, key, parseUUID)
+}
+
+// GetURLParamEnum validates that the url parameter is one of the allowed values.
+func GetURLParamEnum(r *http.Request, key string, allowed ...string) (string, *Error) {
+	return ParseURLParam(r, key, enumParser(allowed))
+}
@@ -0,0 +1,156 @@
+package rest
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator can be implemented by decoded request objects to report all invalid
+// fields at once. Decode calls Validate after a successful deserialization and
+// responds with a 400 error listing the returned violations.
+type Validator interface {
+	Validate() []FieldViolation
+}
+
+// FieldViolation describes why a single field of a request is invalid.
+type FieldViolation struct {
+	// Field is the JSON pointer (RFC 6901) to the offending field, e.g.
+	// "/spec/replicas" or "/topics/0/name".
+	Field string `json:"field"`
+	// Rule is a short machine-readable identifier of the violated rule,
+	// e.g. "required" or "maxLength".
+	Rule string `json:"rule"`
+	// Message is a human-readable description of the violation.
+	Message string `json:"message"`
+}
+
+// ValidationErrors is a list of field violations that implements the error
+// interface. It can be returned from OK() implementations so that Decode
+// reports the individual violations.
+type ValidationErrors []FieldViolation
+
+// Error implements the error interface.
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, violation := range v {
+		msgs[i] = fmt.Sprintf("%s: %s", violation.Field, violation.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate collects the given violations into ValidationErrors. Nil violations
+// (which are returned by rules that are satisfied) are skipped. If no rule was
+// violated nil is returned. It is meant to be used inside OK() implementations:
+//
+//	func (req *CreateTopicRequest) OK() error {
+//		return rest.Validate(
+//			rest.Required("/name", req.Name),
+//			rest.MaxLength("/name", req.Name, 249),
+//			rest.Range("/partitionCount", req.PartitionCount, 1, 1000),
+//			rest.Enum("/cleanupPolicy", req.CleanupPolicy, "delete", "compact"),
+//		)
+//	}
+func Validate(violations ...*FieldViolation) error {
+	var errs ValidationErrors
+	for _, v := range violations {
+		if v != nil {
+			errs = append(errs, *v)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// JSONPointer builds a JSON pointer from the given reference tokens and escapes
+// them as specified in RFC 6901.
+func JSONPointer(tokens ...string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteByte('/')
+		token = strings.ReplaceAll(token, "~", "~0")
+		token = strings.ReplaceAll(token, "/", "~1")
+		sb.WriteString(token)
+	}
+	return sb.String()
+}
+
+// Required reports a violation if value is the zero value of its type.
+func Required[T comparable](field string, value T) *FieldViolation {
+	var zero T
+	if value != zero {
+		return nil
+	}
+	return &FieldViolation{Field: field, Rule: "required", Message: "must be set"}
+}
+
+// MinLength reports a violation if value has fewer than min characters.
+func MinLength(field string, value string, min int) *FieldViolation {
+	if utf8.RuneCountInString(value) >= min {
+		return nil
+	}
+	return &FieldViolation{
+		Field:   field,
+		Rule:    "minLength",
+		Message: fmt.Sprintf("must be at least %d characters long", min),
+	}
+}
+
+// MaxLength reports a violation if value has more than max characters.
+func MaxLength(field string, value string, max int) *FieldViolation {
+	if utf8.RuneCountInString(value) <= max {
+		return nil
+	}
+	return &FieldViolation{
+		Field:   field,
+		Rule:    "maxLength",
+		Message: fmt.Sprintf("must be at most %d characters long", max),
+	}
+}
+
+// Enum reports a violation if value is not one of the allowed values.
+func Enum[T comparable](field string, value T, allowed ...T) *FieldViolation {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+
+	allowedStr := make([]string, len(allowed))
+	for i, a := range allowed {
+		allowedStr[i] = fmt.Sprintf("%v", a)
+	}
+	return &FieldViolation{
+		Field:   field,
+		Rule:    "enum",
+		Message: fmt.Sprintf("must be one of [%s]", strings.Join(allowedStr, ", ")),
+	}
+}
+
+// Match reports a violation if value does not match the regular expression.
+func Match(field string, value string, re *regexp.Regexp) *FieldViolation {
+	if re.MatchString(value) {
+		return nil
+	}
+	return &FieldViolation{
+		Field:   field,
+		Rule:    "pattern",
+		Message: fmt.Sprintf("must match the pattern %q", re.String()),
+	}
+}
+
+// Range reports a violation if value is not within [min, max].
+func Range[T cmp.Ordered](field string, value, min, max T) *FieldViolation {
+	if value >= min && value <= max {
+		return nil
+	}
+	return &FieldViolation{
+		Field:   field,
+		Rule:    "range",
+		Message: fmt.Sprintf("must be between %v and %v", min, max),
+	}
+}